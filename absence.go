@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Absence is a period when the user is out of office and excused from all rounds in the team.
+type Absence struct {
+	ID        uint64 // absence identifier, unique within the team
+	TeamID    string // slack team identifier
+	User      string // slack user identifier of the absent user
+	From      string // first day of the absence, YYYY-MM-DD
+	To        string // last day of the absence, YYYY-MM-DD
+	CreatedBy string // slack user identifier of the user who entered the absence
+}
+
+// Covers reports whether the absence includes the given day in YYYY-MM-DD format.
+func (a *Absence) Covers(day string) bool {
+	return a.From <= day && day <= a.To
+}
+
+// NewAbsence validates the dates and creates an absence, to defaults to from.
+func NewAbsence(teamID, user, from, to, createdBy string) (Absence, error) {
+	if to == "" {
+		to = from
+	}
+
+	for _, day := range []string{from, to} {
+		_, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return Absence{}, fmt.Errorf("invalid date %q", day)
+		}
+	}
+	if to < from {
+		return Absence{}, fmt.Errorf("absence ends before it starts")
+	}
+
+	return Absence{TeamID: teamID, User: user, From: from, To: to, CreatedBy: createdBy}, nil
+}
+
+// Save stores the absence and excuses the user from the open rounds it covers.
+func (a *Absence) Save() error {
+	err := App.store.SaveAbsence(a)
+	if err != nil {
+		return err
+	}
+
+	instances, err := App.store.ListOpenInstances()
+	if err != nil {
+		return err
+	}
+	instances = slices.DeleteFunc(instances, func(qi QuestionInstance) bool { return qi.Question.TeamID != a.TeamID })
+
+	return excuseAbsentUsers(instances, time.Now())
+}
+
+// Delete removes the absence and asks the user again in the open rounds it excused them from,
+// unless another absence still covers them today.
+func (a *Absence) Delete() error {
+	err := App.store.DeleteAbsence(a.TeamID, a.ID)
+	if err != nil {
+		return err
+	}
+
+	return a.unexcuse(time.Now())
+}
+
+// unexcuse marks the user as missing again in the open instances of the team which ran during the absence.
+func (a *Absence) unexcuse(now time.Time) error {
+	instances, err := App.store.ListOpenInstances()
+	if err != nil {
+		return err
+	}
+	absences, err := App.store.ListAbsences(a.TeamID)
+	if err != nil {
+		return err
+	}
+
+	for _, qi := range instances {
+		if qi.Question.TeamID != a.TeamID || qi.Responses[a.User] != ResponseExcused {
+			continue
+		}
+
+		posted, err := qi.PostedAt()
+		if err != nil {
+			continue
+		}
+		loc := qi.Question.location()
+		today := now.In(loc).Format(time.DateOnly)
+		if a.To < posted.In(loc).Format(time.DateOnly) || a.From > today {
+			continue
+		}
+
+		if slices.Contains(absentOn(absences, today), a.User) {
+			continue
+		}
+
+		err = updateInstance(qi.Question.Channel, qi.Timestamp, qi.Question, func(qi *QuestionInstance) error {
+			if !qi.IsOpen() || qi.Responses[a.User] != ResponseExcused {
+				return nil
+			}
+
+			qi.Responses[a.User] = ResponseMissing
+			err := qi.Save()
+			if err != nil {
+				return err
+			}
+
+			err = qi.PostMessage()
+			if err != nil {
+				log.Error("Could not update message.", "question", qi.QuestionID, "instance", qi.Timestamp, "err", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// absentUsers returns the users of the team who are absent on the given day.
+func absentUsers(teamID string, day string) ([]string, error) {
+	absences, err := App.store.ListAbsences(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	return absentOn(absences, day), nil
+}
+
+// absentOn returns the users with an absence covering the given day.
+func absentOn(absences []Absence, day string) []string {
+	var users []string
+	for _, absence := range absences {
+		if absence.Covers(day) && !slices.Contains(users, absence.User) {
+			users = append(users, absence.User)
+		}
+	}
+	return users
+}
+
+// excuseAbsentUsers marks users who are absent today as excused in the given open instances.
+// Absences are loaded once for every team of the instances. The instances are reloaded under their lock
+// and replaced with the updated copies.
+func excuseAbsentUsers(instances []QuestionInstance, now time.Time) error {
+	absences := map[string][]Absence{}
+	for i := range instances {
+		qi := &instances[i]
+		teamID := qi.Question.TeamID
+		if _, ok := absences[teamID]; !ok {
+			teamAbsences, err := App.store.ListAbsences(teamID)
+			if err != nil {
+				return fmt.Errorf("could not list absences of team %s: %w", teamID, err)
+			}
+			absences[teamID] = teamAbsences
+		}
+
+		absent := absentOn(absences[teamID], now.In(qi.Question.location()).Format(time.DateOnly))
+		if !slices.ContainsFunc(absent, func(user string) bool { return qi.Responses[user] == ResponseMissing }) {
+			continue
+		}
+
+		err := updateInstance(qi.Question.Channel, qi.Timestamp, qi.Question, func(fresh *QuestionInstance) error {
+			*qi = *fresh
+			if !qi.IsOpen() {
+				return nil
+			}
+
+			changed := false
+			for _, user := range absent {
+				if qi.Responses[user] == ResponseMissing {
+					qi.Responses[user] = ResponseExcused
+					changed = true
+				}
+			}
+			if !changed {
+				return nil
+			}
+
+			err := qi.Save()
+			if err != nil {
+				return err
+			}
+
+			err = qi.PostMessage()
+			if err != nil {
+				log.Error("Could not update message.", "question", qi.QuestionID, "instance", qi.Timestamp, "err", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsManager reports whether the user may manage absences of other users in the team.
+// Managers are workspace admins and owners, and users listed in MANAGERS.
+func IsManager(teamID string, user string) (bool, error) {
+	if slices.Contains(App.config.Managers, user) {
+		return true, nil
+	}
+
+	client, ok := App.slack[teamID]
+	if !ok {
+		return false, fmt.Errorf("not connected to team %s", teamID)
+	}
+
+	info, err := client.GetUserInfo(user)
+	if err != nil {
+		return false, err
+	}
+	return info.IsAdmin || info.IsOwner, nil
+}
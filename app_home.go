@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	homeSettingsActionID = "home_settings" // checkboxes on the App Home with the notification settings
+
+	homePendingLimit = 20 // a view holds at most 100 blocks, each open round takes two
+	homeRecentLimit  = 5
+	homeHistoryDays  = 90 // how far back the history and the streak reach
+)
+
+// values of the notification checkboxes, a checked box means the notification is on
+const (
+	settingReminders = "reminders"
+	settingQuorum    = "quorum"
+)
+
+var homeAnswerIcons = map[ResponseStatus]string{
+	ResponseAnswered: "✅",
+	ResponseNothing:  "🤷",
+	ResponseLate:     "🐢",
+}
+
+func handleAppHomeOpened(evt *socketmode.Event, client *socketmode.Client) {
+	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+	if !ok {
+		log.Warn("Invalid event data.", "evt", *evt)
+		return
+	}
+	client.Ack(*evt.Request)
+
+	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.AppHomeOpenedEvent)
+	if !ok {
+		log.Warn("Invalid event data.", "ev", eventsAPIEvent.InnerEvent.Data)
+		return
+	}
+	if ev.Tab != "home" {
+		return
+	}
+
+	err := publishHome(eventsAPIEvent.TeamID, ev.User)
+	if err != nil {
+		log.Error("Could not publish App Home.", "team", eventsAPIEvent.TeamID, "user", ev.User, "err", err)
+	}
+}
+
+func handleSettingsAction(evt *socketmode.Event, client *socketmode.Client) {
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok || len(callback.ActionCallback.BlockActions) == 0 {
+		log.Warn("Invalid event data.", "evt", *evt)
+		return
+	}
+	client.Ack(*evt.Request)
+
+	logger := log.With("team", callback.Team.ID, "user", callback.User.ID)
+
+	var enabled []string
+	for _, option := range callback.ActionCallback.BlockActions[0].SelectedOptions {
+		enabled = append(enabled, option.Value)
+	}
+
+	settings, err := LoadUserSettings(callback.Team.ID, callback.User.ID)
+	if err != nil {
+		logger.Error("Could not load user settings.", "err", err)
+		return
+	}
+
+	settings.MuteReminders = !slices.Contains(enabled, settingReminders)
+	settings.MuteQuorum = !slices.Contains(enabled, settingQuorum)
+	err = settings.Save()
+	if err != nil {
+		logger.Error("Could not save user settings.", "err", err)
+		return
+	}
+	logger.Info("User settings changed.", "mute_reminders", settings.MuteReminders, "mute_quorum", settings.MuteQuorum)
+}
+
+// publishHome renders the App Home of the user with their open rounds, recent answers and notification settings.
+func publishHome(teamID, user string) error {
+	client, ok := App.slack[teamID]
+	if !ok {
+		return fmt.Errorf("not connected to team %s", teamID)
+	}
+
+	pending, err := pendingInstances(teamID, user)
+	if err != nil {
+		return fmt.Errorf("could not list open rounds: %w", err)
+	}
+
+	history, err := userHistory(teamID, user)
+	if err != nil {
+		return fmt.Errorf("could not list past rounds: %w", err)
+	}
+
+	settings, err := LoadUserSettings(teamID, user)
+	if err != nil {
+		return fmt.Errorf("could not load user settings: %w", err)
+	}
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Čaká na tvoju odpoveď", true, false)),
+	}
+	blocks = append(blocks, pendingBlocks(client, pending)...)
+	blocks = append(blocks,
+		slack.NewDividerBlock(),
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Tvoje posledné odpovede", true, false)),
+	)
+	blocks = append(blocks, historyBlocks(history, user)...)
+	blocks = append(blocks,
+		slack.NewDividerBlock(),
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Upozornenia", true, false)),
+		settingsBlock(settings),
+	)
+
+	view := slack.HomeTabViewRequest{Type: slack.VTHomeTab, Blocks: slack.Blocks{BlockSet: blocks}}
+	_, err = client.PublishView(user, view, "")
+	return err
+}
+
+// pendingInstances returns the open rounds in the team which the user did not answer yet, oldest first.
+func pendingInstances(teamID, user string) ([]QuestionInstance, error) {
+	instances, err := App.store.ListOpenInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []QuestionInstance
+	for _, qi := range instances {
+		if qi.Question.TeamID == teamID && !qi.Question.IsDeleted() && qi.IsOpen() && qi.Responses[user] == ResponseMissing {
+			pending = append(pending, qi)
+		}
+	}
+
+	slices.SortFunc(pending, func(a, b QuestionInstance) int {
+		return strings.Compare(a.Timestamp, b.Timestamp)
+	})
+	return pending, nil
+}
+
+// userHistory returns the rounds in the team from the last homeHistoryDays which the user was asked in,
+// newest first. Archived rounds are not included.
+func userHistory(teamID, user string) ([]QuestionInstance, error) {
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().AddDate(0, 0, -homeHistoryDays)
+	var history []QuestionInstance
+	for i := range questions {
+		if questions[i].TeamID != teamID || questions[i].IsDeleted() {
+			continue
+		}
+
+		instances, err := App.store.ListQuestionInstances(questions[i].ID, since)
+		if err != nil {
+			return nil, fmt.Errorf("question %d: %w", questions[i].ID, err)
+		}
+		for _, qi := range instances {
+			if _, ok := qi.Responses[user]; ok {
+				qi.Question = &questions[i]
+				history = append(history, qi)
+			}
+		}
+	}
+
+	slices.SortFunc(history, func(a, b QuestionInstance) int {
+		return strings.Compare(b.Timestamp, a.Timestamp)
+	})
+	return history, nil
+}
+
+// answerStreak counts the latest rounds in a row which the user answered in time, given their history newest first.
+// Rounds the user was excused from or skipped do not break the streak, open rounds count once they are answered.
+func answerStreak(history []QuestionInstance, user string) int {
+	streak := 0
+	for _, qi := range history {
+		switch qi.Responses[user] {
+		case ResponseAnswered, ResponseNothing:
+			streak++
+		case ResponseMissing:
+			if !qi.IsOpen() {
+				return streak
+			}
+		case ResponseLate:
+			return streak
+		}
+	}
+	return streak
+}
+
+// userAnswer returns the latest answer of the user in the round, on a single line.
+func userAnswer(qi *QuestionInstance, user string) string {
+	for i := len(qi.Answers) - 1; i >= 0; i-- {
+		if qi.Answers[i].User != user {
+			continue
+		}
+
+		var texts []string
+		for _, field := range qi.Answers[i].Fields {
+			if field.Text != "" {
+				texts = append(texts, field.Text)
+			}
+		}
+		return strings.Join(strings.Fields(strings.Join(texts, " ")), " ")
+	}
+
+	for i := len(qi.Replies) - 1; i >= 0; i-- {
+		if qi.Replies[i].User == user {
+			return strings.Join(strings.Fields(qi.Replies[i].Text), " ")
+		}
+	}
+	return ""
+}
+
+// pendingBlocks lists the open rounds with a link to their thread and buttons to answer them right away.
+func pendingBlocks(client *slack.Client, pending []QuestionInstance) []slack.Block {
+	if len(pending) == 0 {
+		return []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "Všetky updaty máš hotové. 🎉", false, false), nil, nil),
+		}
+	}
+
+	var blocks []slack.Block
+	for i, qi := range pending {
+		if i == homePendingLimit {
+			more := fmt.Sprintf("…a ďalšie kolá: %d", len(pending)-homePendingLimit)
+			blocks = append(blocks, slack.NewContextBlock("pending-more", slack.NewTextBlockObject(slack.MarkdownType, more, false, false)))
+			break
+		}
+
+		loc := qi.Question.location()
+		var details []string
+		posted, err := qi.PostedAt()
+		if err == nil {
+			details = append(details, "Začalo "+posted.In(loc).Format("2. 1. o 15:04"))
+		}
+		if !qi.Deadline.IsZero() {
+			details = append(details, "⏰ termín "+qi.Deadline.In(loc).Format("2. 1. o 15:04"))
+		}
+		permalink, err := client.GetPermalink(&slack.PermalinkParameters{Channel: qi.Question.Channel, Ts: qi.Timestamp})
+		if err != nil {
+			log.Error("Could not get permalink.", "channel", qi.Question.Channel, "ts", qi.Timestamp, "err", err)
+		} else {
+			details = append(details, fmt.Sprintf("<%s|Prejsť na thread>", permalink))
+		}
+
+		value := instanceValue(qi.Question.Channel, qi.Timestamp)
+		text := fmt.Sprintf("*<#%s>* %s\n%s", qi.Question.Channel, qi.Question.summary(), strings.Join(details, " · "))
+		answer := slack.NewButtonBlockElement(answerActionID, value, slack.NewTextBlockObject(slack.PlainTextType, "Odpovedať", false, false)).
+			WithStyle(slack.StylePrimary)
+
+		blocks = append(blocks,
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, slack.NewAccessory(answer)),
+			slack.NewActionBlock("pending-"+value,
+				slack.NewButtonBlockElement(nothingActionID, value, slack.NewTextBlockObject(slack.PlainTextType, "🤷 Nič nové", true, false)),
+				slack.NewButtonBlockElement(skipActionID, value, slack.NewTextBlockObject(slack.PlainTextType, "⏭️ Vynechám toto kolo", true, false)),
+			),
+		)
+	}
+	return blocks
+}
+
+// historyBlocks shows the streak of the user and their latest answers.
+func historyBlocks(history []QuestionInstance, user string) []slack.Block {
+	streak := fmt.Sprintf("🔥 Séria odpovedí včas: *%d*", answerStreak(history, user))
+	blocks := []slack.Block{
+		slack.NewContextBlock("streak", slack.NewTextBlockObject(slack.MarkdownType, streak, false, false)),
+	}
+
+	var lines []string
+	for _, qi := range history {
+		icon, ok := homeAnswerIcons[qi.Responses[user]]
+		if !ok {
+			continue
+		}
+
+		line := fmt.Sprintf("%s *<#%s>* %s", icon, qi.Question.Channel, qi.Question.summary())
+		if posted, err := qi.PostedAt(); err == nil {
+			line += " · " + posted.In(qi.Question.location()).Format("2. 1. 2006")
+		}
+		if answer := userAnswer(&qi, user); answer != "" {
+			line += "\n> " + shorten(answer, 100)
+		}
+
+		lines = append(lines, line)
+		if len(lines) == homeRecentLimit {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Zatiaľ si neodpovedal/-a na žiadnu buzeráciu.")
+	}
+
+	return append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n\n"), false, false), nil, nil))
+}
+
+// settingsBlock offers the notification settings of the user as checkboxes, which are saved as soon as they change.
+func settingsBlock(settings UserSettings) slack.Block {
+	reminders := slack.NewOptionBlockObject(settingReminders,
+		slack.NewTextBlockObject(slack.PlainTextType, "Pripomienky", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Súkromná správa, keď ti v niektorom kanáli chýba update.", false, false))
+	quorum := slack.NewOptionBlockObject(settingQuorum,
+		slack.NewTextBlockObject(slack.PlainTextType, "Kvórum", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Súkromná správa, keď tvoja buzerácia dosiahne kvórum.", false, false))
+
+	checkboxes := slack.NewCheckboxGroupsBlockElement(homeSettingsActionID, reminders, quorum)
+	if !settings.MuteReminders {
+		checkboxes.InitialOptions = append(checkboxes.InitialOptions, reminders)
+	}
+	if !settings.MuteQuorum {
+		checkboxes.InitialOptions = append(checkboxes.InitialOptions, quorum)
+	}
+
+	text := slack.NewTextBlockObject(slack.MarkdownType, "Čo ti môžem posielať:", false, false)
+	return slack.NewSectionBlock(text, nil, slack.NewAccessory(checkboxes))
+}
@@ -4,15 +4,15 @@ import (
 	"sync"
 
 	"github.com/slack-go/slack"
-	bolt "go.etcd.io/bbolt"
 )
 
 var App application
 
 type application struct {
-	db     *bolt.DB
-	slack  map[string]*slack.Client
-	wg     sync.WaitGroup
-	webUI  *webUI
-	config Config
+	store    Store
+	slack    map[string]*slack.Client
+	botUsers map[string]string // bot user ID in each team
+	wg       sync.WaitGroup
+	webUI    *webUI
+	config   Config
 }
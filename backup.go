@@ -0,0 +1,257 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const backupCron = "15 2 * * *"
+
+const backupPrefix = "buzerator-"
+const backupTimeFormat = "2006-01-02T150405"
+
+func backupExtension() string {
+	if App.config.DatabaseDriver == DriverSQLite {
+		return ".sqlite"
+	}
+	return ".db"
+}
+
+// CreateBackup writes a snapshot of the running database into the backup directory.
+func CreateBackup(now time.Time) (string, error) {
+	err := os.MkdirAll(App.config.BackupDir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(App.config.BackupDir, backupPrefix+now.Format(backupTimeFormat)+backupExtension())
+
+	// write into a temporary file first, so that a crash never leaves a truncated snapshot behind
+	f, err := os.CreateTemp(App.config.BackupDir, ".snapshot-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = App.store.Backup(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err != nil {
+		return "", err
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+
+	return filename, os.Rename(f.Name(), filename)
+}
+
+type backupFile struct {
+	Path    string
+	Created time.Time
+}
+
+func listBackups() ([]backupFile, error) {
+	entries, err := os.ReadDir(App.config.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupExtension()) {
+			continue
+		}
+
+		created, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupExtension()), time.Local)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backupFile{Path: filepath.Join(App.config.BackupDir, name), Created: created})
+	}
+
+	// newest first
+	slices.SortFunc(backups, func(a, b backupFile) int {
+		return b.Created.Compare(a.Created)
+	})
+	return backups, nil
+}
+
+// RotateBackups keeps the newest backup of each of the last keepDaily days
+// and of each of the last keepWeekly weeks, and deletes the rest.
+func RotateBackups(keepDaily int, keepWeekly int) error {
+	backups, err := listBackups()
+	if err != nil {
+		return err
+	}
+
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for _, backup := range backups {
+		day := backup.Created.Format(time.DateOnly)
+		year, week := backup.Created.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+
+		keep := false
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep = true
+		}
+		if !weeks[weekKey] && len(weeks) < keepWeekly {
+			weeks[weekKey] = true
+			keep = true
+		}
+
+		if keep {
+			continue
+		}
+
+		log.Info("Removing old backup.", "file", backup.Path)
+		err = os.Remove(backup.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestoreBackup validates the snapshot and replaces the database file with it.
+// The replaced database is kept next to it. The server must not be running,
+// see checkDatabaseUnused.
+func RestoreBackup(snapshot string) error {
+	target := App.config.DatabaseFile
+
+	if _, err := os.Stat(target); err == nil {
+		err = checkDatabaseUnused(target)
+		if err != nil {
+			return fmt.Errorf("%w, is the server still running?", err)
+		}
+	}
+
+	// validate a copy placed next to the target, so that the final swap is a rename
+	candidate := target + ".restore"
+	err := copyFile(snapshot, candidate)
+	if err != nil {
+		return fmt.Errorf("could not copy snapshot: %w", err)
+	}
+	defer os.Remove(candidate)
+
+	err = validateSnapshot(candidate)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	previous := fmt.Sprintf("%s.before-restore-%s", target, time.Now().Format(backupTimeFormat))
+	err = os.Rename(target, previous)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		log.Info("Previous database moved.", "file", previous)
+	}
+
+	return os.Rename(candidate, target)
+}
+
+// checkDatabaseUnused fails if another process has the database open. A bbolt file is locked by the process
+// using it. SQLite keeps the write-ahead log and its index next to the database while it is open, and
+// leaves them behind after a crash with changes which are not in the database file yet, so restoring
+// over them would mix the log of one database with another.
+func checkDatabaseUnused(filename string) error {
+	if App.config.DatabaseDriver != DriverSQLite {
+		current, err := OpenStoreReadOnly(App.config.DatabaseDriver, filename)
+		if err != nil {
+			return fmt.Errorf("could not open current database: %w", err)
+		}
+		return current.Close()
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(filename + suffix); err == nil {
+			return fmt.Errorf("%s exists, start and stop the server once if it crashed", filename+suffix)
+		}
+	}
+
+	// in exclusive locking mode SQLite does not create the log index, so opening the database has no side effects
+	db, err := sql.Open("sqlite", "file:"+filename+"?_pragma=locking_mode(EXCLUSIVE)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("BEGIN EXCLUSIVE; ROLLBACK")
+	if err != nil {
+		return fmt.Errorf("could not lock current database: %w", err)
+	}
+	return nil
+}
+
+func validateSnapshot(filename string) error {
+	store, err := OpenStoreReadOnly(App.config.DatabaseDriver, filename)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	err = store.Check()
+	if err != nil {
+		return err
+	}
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return fmt.Errorf("snapshot has no schema version")
+	}
+	if version > latestSchemaVersion() {
+		return fmt.Errorf("snapshot schema version %d is newer than %d supported by this binary", version, latestSchemaVersion())
+	}
+
+	_, err = store.ListQuestions()
+	if err != nil {
+		return fmt.Errorf("could not read questions: %w", err)
+	}
+	_, err = store.ListTeams()
+	if err != nil {
+		return fmt.Errorf("could not read teams: %w", err)
+	}
+	return nil
+}
+
+func copyFile(from string, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(destination, source)
+	if err == nil {
+		err = destination.Sync()
+	}
+	closeErr := destination.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTestDatabase configures a database file and a backup directory in a temporary directory
+// and opens the database as the store of the application.
+func useTestDatabase(t *testing.T, driver string) {
+	t.Helper()
+
+	config, store := App.config, App.store
+	t.Cleanup(func() { App.config, App.store = config, store })
+
+	dir := t.TempDir()
+	App.config.DatabaseDriver = driver
+	App.config.DatabaseFile = filepath.Join(dir, "buzerator"+backupExtension())
+	App.config.BackupDir = filepath.Join(dir, "backups")
+
+	var err error
+	App.store, err = OpenStore(driver, App.config.DatabaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = App.store.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	for _, driver := range storeDrivers {
+		t.Run(driver, func(t *testing.T) {
+			useTestDatabase(t, driver)
+
+			err := App.store.SaveQuestion(&Question{TeamID: "T1", Channel: "C1", Message: "Zálohovaná"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			snapshot, err := CreateBackup(time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = App.store.SaveQuestion(&Question{TeamID: "T1", Channel: "C1", Message: "Po zálohe"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if driver == DriverSQLite {
+				// the open database keeps its write-ahead log next to it
+				err = RestoreBackup(snapshot)
+				if err == nil {
+					t.Error("RestoreBackup() succeeded while the database is open")
+				}
+			}
+			err = App.store.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			original, err := os.ReadFile(snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validateSnapshot(snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			validated, err := os.ReadFile(snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(original, validated) {
+				t.Error("validating the snapshot changed it")
+			}
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if _, err := os.Stat(snapshot + suffix); err == nil {
+					t.Errorf("validating the snapshot created %s", snapshot+suffix)
+				}
+			}
+
+			if driver == DriverSQLite {
+				// left behind by a crashed server
+				err = os.WriteFile(App.config.DatabaseFile+"-wal", nil, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = RestoreBackup(snapshot)
+				if err == nil {
+					t.Error("RestoreBackup() succeeded with a write-ahead log left behind")
+				}
+				err = os.Remove(App.config.DatabaseFile + "-wal")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = RestoreBackup(snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if _, err := os.Stat(App.config.DatabaseFile + suffix); err == nil {
+					t.Errorf("restoring left %s behind", App.config.DatabaseFile+suffix)
+				}
+			}
+
+			err = RestoreBackup(filepath.Join(t.TempDir(), "missing"+backupExtension()))
+			if err == nil {
+				t.Error("RestoreBackup() of a missing snapshot succeeded")
+			}
+
+			App.store, err = OpenStore(driver, App.config.DatabaseFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer App.store.Close()
+			questions, err := App.store.ListQuestions()
+			if err != nil || len(questions) != 1 || questions[0].Message != "Zálohovaná" {
+				t.Errorf("ListQuestions() after restoring = %+v, %v, want only the question from the backup", questions, err)
+			}
+		})
+	}
+}
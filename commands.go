@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+type command struct {
+	Description string
+	Run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"export": {
+		Description: "export teams, questions and their history as JSON",
+		Run:         commandExport,
+	},
+	"import": {
+		Description: "merge a JSON export into the database",
+		Run:         commandImport,
+	},
+	"migrate-sqlite": {
+		Description: "copy a bbolt database into a new SQLite database",
+		Run:         commandMigrateSQLite,
+	},
+	"restore": {
+		Description: "replace the database with a validated snapshot, the server must be stopped",
+		Run:         commandRestore,
+	},
+	"rotate-key": {
+		Description: "re-encrypt stored Slack tokens with the key from TOKEN_KEY_NEW or TOKEN_KEY_NEW_FILE",
+		Run:         commandRotateKey,
+	},
+}
+
+func RunCommand(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		printCommands()
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	return cmd.Run(args)
+}
+
+func printCommands() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Available commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].Description)
+	}
+}
+
+func commandMigrateSQLite(args []string) error {
+	flags := flag.NewFlagSet("migrate-sqlite", flag.ExitOnError)
+	from := flags.String("from", "data.db", "source bbolt database")
+	to := flags.String("to", "data.sqlite", "destination SQLite database, must not exist yet")
+	flags.Parse(args)
+
+	_, err := os.Stat(*to)
+	if err == nil {
+		return fmt.Errorf("destination %s already exists", *to)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	source, err := OpenStore(DriverBolt, *from)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", *from, err)
+	}
+	defer source.Close()
+
+	err = source.Migrate()
+	if err != nil {
+		return fmt.Errorf("could not migrate %s: %w", *from, err)
+	}
+
+	destination, err := OpenStore(DriverSQLite, *to)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", *to, err)
+	}
+	defer destination.Close()
+
+	err = destination.Migrate()
+	if err != nil {
+		return fmt.Errorf("could not migrate %s: %w", *to, err)
+	}
+
+	log.Info("Copying database.", "from", *from, "to", *to)
+	err = CopyStore(source, destination)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Database copied. Set DATABASE_DRIVER=sqlite and DATABASE_FILE to use it.", "file", *to)
+	return nil
+}
+
+func commandRotateKey(args []string) error {
+	flags := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	flags.Parse(args)
+
+	newKey, err := loadMasterKey("TOKEN_KEY_NEW")
+	if err != nil {
+		return err
+	}
+	if newKey == nil {
+		return fmt.Errorf("set TOKEN_KEY_NEW or TOKEN_KEY_NEW_FILE to the new key")
+	}
+
+	err = OpenDatabase(App.config.DatabaseDriver, App.config.DatabaseFile)
+	if err != nil {
+		return fmt.Errorf("could not open database: %w", err)
+	}
+	defer App.store.Close()
+
+	teams, err := App.store.ListTeams()
+	if err != nil {
+		return err
+	}
+
+	// tokens already encrypted with the new key are skipped, so an interrupted rotation can be run again
+	newKeyID := keyID(newKey)
+
+	// check that all tokens can be decrypted before changing anything
+	for _, team := range teams {
+		if team.SealedToken != nil && team.SealedToken.KeyID != newKeyID {
+			_, err = team.SealedToken.open(App.config.TokenKey, team.ID)
+			if err != nil {
+				return fmt.Errorf("team %s: %w", team.ID, err)
+			}
+		}
+	}
+
+	for _, team := range teams {
+		if team.SealedToken != nil && team.SealedToken.KeyID == newKeyID {
+			continue
+		}
+
+		if team.SealedToken != nil {
+			err = team.SealedToken.rewrap(App.config.TokenKey, newKey)
+		} else if team.Token != "" {
+			team.SealedToken, err = sealSecret(newKey, team.Token, team.ID)
+			team.Token = ""
+		}
+		if err != nil {
+			return fmt.Errorf("team %s: %w", team.ID, err)
+		}
+
+		err = App.store.SaveTeam(&team)
+		if err != nil {
+			return fmt.Errorf("could not save team %s: %w", team.ID, err)
+		}
+		log.Info("Token re-encrypted.", "team", team.ID)
+	}
+
+	log.Info("All tokens re-encrypted. Replace TOKEN_KEY with the new key.", "key", newKeyID)
+	return nil
+}
+
+func commandRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: buzerator restore <snapshot>")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a snapshot file")
+	}
+
+	err := RestoreBackup(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	log.Info("Database restored.", "snapshot", flags.Arg(0), "file", App.config.DatabaseFile)
+	return nil
+}
+
+// mappingFlag collects repeated OLD=NEW flag values.
+type mappingFlag map[string]string
+
+func (m mappingFlag) String() string {
+	var pairs []string
+	for from, to := range m {
+		pairs = append(pairs, from+"="+to)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m mappingFlag) Set(value string) error {
+	from, to, ok := strings.Cut(value, "=")
+	if !ok || from == "" || to == "" {
+		return fmt.Errorf("expected OLD=NEW")
+	}
+	m[from] = to
+	return nil
+}
+
+func commandExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "-", "output file, - for standard output")
+	team := flags.String("team", "", "only export this team")
+	channel := flags.String("channel", "", "only export this channel, requires -team")
+	includeTokens := flags.Bool("include-tokens", false, "export Slack tokens, encrypted with the configured key if there is one")
+	flags.Parse(args)
+
+	if *channel != "" && *team == "" {
+		return fmt.Errorf("-channel requires -team")
+	}
+	if *includeTokens && App.config.TokenKey == nil {
+		log.Warn("No encryption key configured, exporting Slack tokens in plaintext.")
+	}
+
+	err := OpenDatabase(App.config.DatabaseDriver, App.config.DatabaseFile)
+	if err != nil {
+		return fmt.Errorf("could not open database: %w", err)
+	}
+	defer App.store.Close()
+
+	doc, err := ExportData(ExportOptions{
+		TeamID:        *team,
+		Channel:       *channel,
+		IncludeTokens: *includeTokens,
+	})
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Exported.", "teams", len(doc.Teams), "questions", len(doc.Questions), "instances", len(doc.Instances))
+	return nil
+}
+
+func commandImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be imported")
+	inactive := flags.Bool("inactive", false, "import all questions as inactive")
+	teams := mappingFlag{}
+	flags.Var(teams, "team", "map an exported team to another one, OLD=NEW, can be repeated")
+	channels := mappingFlag{}
+	flags.Var(channels, "channel", "map an exported channel to another one, OLD=NEW, can be repeated")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: buzerator import [flags] <file>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an export file")
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var doc Export
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return fmt.Errorf("could not parse export: %w", err)
+	}
+
+	err = OpenDatabase(App.config.DatabaseDriver, App.config.DatabaseFile)
+	if err != nil {
+		return fmt.Errorf("could not open database: %w", err)
+	}
+	defer App.store.Close()
+
+	report, err := ImportData(doc, ImportOptions{
+		DryRun:   *dryRun,
+		Inactive: *inactive,
+		Teams:    teams,
+		Channels: channels,
+	})
+
+	for _, conflict := range report.Conflicts {
+		log.Warn("Conflict.", "detail", conflict)
+	}
+	for _, warning := range report.Warnings {
+		log.Warn("Warning.", "detail", warning)
+	}
+	for oldID, newID := range report.QuestionIDMapping {
+		log.Debug("Question imported.", "old", oldID, "new", newID)
+	}
+	if err != nil {
+		return err
+	}
+
+	message := "Imported."
+	if *dryRun {
+		message = "Dry run, nothing was imported."
+	}
+	log.Info(message, "teams", report.TeamsCreated, "absences", report.AbsencesCreated, "holidays", report.HolidaysCreated, "templates", report.TemplatesCreated, "settings", report.SettingsCreated, "questions", report.QuestionsCreated, "instances", report.InstancesCreated, "conflicts", len(report.Conflicts))
+	return nil
+}
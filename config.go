@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -12,9 +13,20 @@ type Config struct {
 	SlackAppToken     string
 	RootURL           string
 	ListenAddress     string
+	DatabaseDriver    string
 	DatabaseFile      string
 	Debug             bool
-	MigrateToTeam string
+	MigrateToTeam     string
+	PollDays          int  // how many days new replies to an instance are checked, 0 means forever
+	ArchiveDays       int  // after how many days instances are archived, 0 disables archival
+	PurgeArchived     bool // whether old instances are deleted instead of archived
+	TokenKey          []byte
+	BackupDir         string // directory for scheduled snapshots, empty disables them
+	BackupKeepDaily   int
+	BackupKeepWeekly  int
+	AdminToken        string   // bearer token for the admin endpoints, empty disables them
+	TrashDays         int      // how many days deleted questions are kept before they are purged, 0 means forever
+	Managers          []string // users who may manage absences of others in addition to workspace admins
 }
 
 func (c *Config) Load() error {
@@ -35,9 +47,20 @@ func (c *Config) Load() error {
 		c.ListenAddress = ":8080"
 	}
 
+	c.DatabaseDriver = os.Getenv("DATABASE_DRIVER")
+	if c.DatabaseDriver == "" {
+		c.DatabaseDriver = DriverBolt
+	}
+	if c.DatabaseDriver != DriverBolt && c.DatabaseDriver != DriverSQLite {
+		return fmt.Errorf("database driver should be %s or %s", DriverBolt, DriverSQLite)
+	}
+
 	c.DatabaseFile = os.Getenv("DATABASE_FILE")
 	if c.DatabaseFile == "" {
 		c.DatabaseFile = "data.db"
+		if c.DatabaseDriver == DriverSQLite {
+			c.DatabaseFile = "data.sqlite"
+		}
 	}
 
 	if os.Getenv("DEBUG") == "true" {
@@ -46,5 +69,63 @@ func (c *Config) Load() error {
 
 	c.MigrateToTeam = os.Getenv("MIGRATE_TEAM")
 
+	var err error
+	c.PollDays, err = intFromEnv("RETENTION_POLL_DAYS", 30)
+	if err != nil {
+		return err
+	}
+
+	c.ArchiveDays, err = intFromEnv("RETENTION_ARCHIVE_DAYS", 0)
+	if err != nil {
+		return err
+	}
+
+	if os.Getenv("RETENTION_PURGE") == "true" {
+		c.PurgeArchived = true
+	}
+
+	c.TokenKey, err = loadMasterKey("TOKEN_KEY")
+	if err != nil {
+		return err
+	}
+
+	c.BackupDir = os.Getenv("BACKUP_DIR")
+
+	c.BackupKeepDaily, err = intFromEnv("BACKUP_KEEP_DAILY", 7)
+	if err != nil {
+		return err
+	}
+
+	c.BackupKeepWeekly, err = intFromEnv("BACKUP_KEEP_WEEKLY", 4)
+	if err != nil {
+		return err
+	}
+
+	c.AdminToken = os.Getenv("ADMIN_TOKEN")
+
+	c.TrashDays, err = intFromEnv("TRASH_DAYS", 30)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range strings.Split(os.Getenv("MANAGERS"), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			c.Managers = append(c.Managers, user)
+		}
+	}
+
 	return nil
 }
+
+func intFromEnv(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("%s should be a non-negative number", strings.ToLower(name))
+	}
+	return number, nil
+}
@@ -1,68 +1,47 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
 
-	bolt "go.etcd.io/bbolt"
+	"github.com/charmbracelet/log"
 )
 
-func OpenDatabase(filename string) error {
+func OpenDatabase(driver string, filename string) error {
 	var err error
-	App.db, err = bolt.Open(filename, 0600, nil)
+	App.store, err = OpenStore(driver, filename)
 	if err != nil {
 		return err
 	}
 
-	err = App.db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte("messages"))
-		if err != nil {
-			return err
-		}
-
-		_, err = tx.CreateBucketIfNotExists([]byte("questions"))
-		if err != nil {
-			return err
-		}
-
-		_, err = tx.CreateBucketIfNotExists([]byte("teams"))
-		if err != nil {
-			return err
-		}
-
-		return nil
-	})
-
+	err = App.store.Migrate()
 	if err != nil {
 		return err
 	}
 
 	if App.config.MigrateToTeam != "" {
-		return migrateDatabase()
+		return assignQuestionsToTeam(App.config.MigrateToTeam)
 	}
-
 	return nil
 }
 
-func migrateDatabase() error {
-	return App.db.Update(func(tx *bolt.Tx) error {
-		questions := tx.Bucket([]byte("questions"))
-
-		return questions.ForEach(func(k, v []byte) error {
-			var question Question
-			err := json.Unmarshal(v, &question)
-			if err != nil {
-				return err
-			}
+// assignQuestionsToTeam assigns questions created before multi-team support, which have no team, to the team.
+func assignQuestionsToTeam(teamID string) error {
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return err
+	}
 
-			if question.TeamID == "" {
-				question.TeamID = App.config.MigrateToTeam
-			}
+	for i := range questions {
+		if questions[i].TeamID != "" {
+			continue
+		}
 
-			data, err := json.Marshal(question)
-			if err != nil {
-				return err
-			}
-			return questions.Put(k, data)
-		})
-	})
+		questions[i].TeamID = teamID
+		err = App.store.SaveQuestion(&questions[i])
+		if err != nil {
+			return fmt.Errorf("could not assign question %d to team: %w", questions[i].ID, err)
+		}
+		log.Info("Assigned question to team.", "question", questions[i].ID, "team", teamID)
+	}
+	return nil
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/slack-go/slack"
+)
+
+// deadlineAt returns when a round posted at the given time closes, zero if the question has no deadline.
+// The deadline is either a duration after posting ("4h", "1h30m") or a time of day ("17:00") in the time
+// zone of the question, in which case the first such time after posting is used.
+func (q *Question) deadlineAt(posted time.Time) (time.Time, error) {
+	if q.Deadline == "" {
+		return time.Time{}, nil
+	}
+
+	clock, err := time.Parse("15:04", q.Deadline)
+	if err == nil {
+		local := posted.In(q.location())
+		deadline := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, q.location())
+		if !deadline.After(posted) {
+			deadline = time.Date(local.Year(), local.Month(), local.Day()+1, clock.Hour(), clock.Minute(), 0, 0, q.location())
+		}
+		return deadline, nil
+	}
+
+	duration, err := time.ParseDuration(q.Deadline)
+	if err != nil || duration <= 0 {
+		return time.Time{}, fmt.Errorf("invalid deadline %q", q.Deadline)
+	}
+	return posted.Add(duration), nil
+}
+
+// CloseExpiredRounds closes all open instances whose deadline has passed.
+func CloseExpiredRounds(now time.Time) error {
+	instances, err := App.store.ListOpenInstances()
+	if err != nil {
+		return err
+	}
+
+	for _, qi := range instances {
+		if qi.Deadline.IsZero() || qi.Deadline.After(now) || qi.Question.IsDeleted() {
+			continue
+		}
+
+		err = updateInstance(qi.Question.Channel, qi.Timestamp, qi.Question, func(qi *QuestionInstance) error {
+			if !qi.IsOpen() {
+				return nil
+			}
+
+			log.Info("Closing round after its deadline.", "question", qi.QuestionID, "instance", qi.Timestamp)
+			err := qi.Close(StatusClosed)
+			if err != nil {
+				return err
+			}
+
+			if qi.Question.AnnounceClose {
+				err = qi.postSummary()
+				if err != nil {
+					log.Error("Could not post round summary.", "question", qi.QuestionID, "instance", qi.Timestamp, "err", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Error("Could not close instance.", "question", qi.QuestionID, "instance", qi.Timestamp, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// postSummary replies to the thread of a closed round with the users who did not answer.
+func (qi *QuestionInstance) postSummary() error {
+	client, ok := App.slack[qi.Question.TeamID]
+	if !ok {
+		return fmt.Errorf("not connected to team %s", qi.Question.TeamID)
+	}
+
+	var missing []string
+	for user, status := range qi.Responses {
+		if status == ResponseMissing {
+			missing = append(missing, fmt.Sprintf("<@%s>", user))
+		}
+	}
+	slices.Sort(missing)
+
+	text := "🔒 Kolo je uzavreté, všetci odpovedali včas 🎉"
+	if len(missing) != 0 {
+		text = fmt.Sprintf("🔒 Kolo je uzavreté. Neodpovedali: %s\nNeskoršie odpovede sa zapíšu ako oneskorené.", strings.Join(missing, ", "))
+	}
+
+	_, _, err := client.PostMessage(qi.Question.Channel, slack.MsgOptionText(text, false), slack.MsgOptionTS(qi.Timestamp))
+	return err
+}
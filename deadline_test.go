@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeadlineAt(t *testing.T) {
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		deadline string
+		posted   string // UTC
+		want     string // UTC, empty means no deadline
+		wantErr  bool
+	}{
+		{"no deadline", "", "2026-03-10 08:00", "", false},
+		{"duration", "4h", "2026-03-10 08:00", "2026-03-10 12:00", false},
+		{"duration with minutes", "1h30m", "2026-03-10 23:00", "2026-03-11 00:30", false},
+		{"time later that day", "17:00", "2026-03-10 08:00", "2026-03-10 16:00", false},
+		{"time already passed", "17:00", "2026-03-10 17:00", "2026-03-11 16:00", false},
+		{"time exactly at posting", "17:00", "2026-03-10 16:00", "2026-03-11 16:00", false},
+		{"local day differs from UTC", "09:00", "2026-03-10 23:30", "2026-03-11 08:00", false},
+		// on 2026-03-29 the clocks jump forward, the deadline keeps its local time
+		{"time across a clock change", "17:00", "2026-03-28 17:00", "2026-03-29 15:00", false},
+		{"invalid", "soon", "2026-03-10 08:00", "", true},
+		{"negative duration", "-1h", "2026-03-10 08:00", "", true},
+		{"zero duration", "0s", "2026-03-10 08:00", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Question{Timezone: "Europe/Bratislava", Deadline: tt.deadline}
+			got, err := q.deadlineAt(utc(tt.posted))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deadlineAt(%q) error = %v, want error %v", tt.deadline, err, tt.wantErr)
+			}
+
+			var want time.Time
+			if tt.want != "" {
+				want = utc(tt.want)
+			}
+			if !got.Equal(want) {
+				t.Errorf("deadlineAt(%q) for a round posted at %s UTC = %s, want %s UTC", tt.deadline, tt.posted, got.UTC(), tt.want)
+			}
+		})
+	}
+}
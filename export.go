@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// exportVersion is the version of the export document format, bump it on incompatible changes.
+// Version 2 added the data of teams: absences, holidays, question templates and user settings.
+// Version 3 made 0 days of retention overrides mean forever instead of the global default.
+const exportVersion = 3
+
+type Export struct {
+	Version      int
+	ExportedAt   time.Time
+	Teams        []Team
+	Absences     []Absence
+	Holidays     []Holiday
+	Templates    []QuestionTemplate
+	UserSettings []UserSettings
+	Questions    []Question
+	Instances    []QuestionInstance
+}
+
+type ExportOptions struct {
+	TeamID        string // only export this team, empty exports all
+	Channel       string // only export this channel of the team, empty exports all
+	IncludeTokens bool   // export bot tokens, sealed with the configured encryption key if there is one
+}
+
+// ExportData collects teams with their data, questions and their instances into a versioned document.
+// Archived instances are not exported.
+func ExportData(opts ExportOptions) (Export, error) {
+	doc := Export{
+		Version:    exportVersion,
+		ExportedAt: time.Now(),
+	}
+
+	if opts.Channel != "" && opts.TeamID == "" {
+		return doc, fmt.Errorf("exporting a channel requires its team")
+	}
+
+	teams, err := ListTeams()
+	if err != nil {
+		return doc, err
+	}
+	for _, team := range teams {
+		if opts.TeamID != "" && team.ID != opts.TeamID {
+			continue
+		}
+
+		team.SealedToken = nil
+		if opts.IncludeTokens && App.config.TokenKey != nil {
+			// the importing deployment has to be configured with the same key
+			team.SealedToken, err = sealSecret(App.config.TokenKey, team.Token, team.ID)
+			if err != nil {
+				return doc, fmt.Errorf("could not encrypt token of team %s: %w", team.ID, err)
+			}
+		}
+		if !opts.IncludeTokens || team.SealedToken != nil {
+			team.Token = ""
+		}
+		doc.Teams = append(doc.Teams, team)
+
+		absences, err := App.store.ListAbsences(team.ID)
+		if err != nil {
+			return doc, fmt.Errorf("could not list absences of team %s: %w", team.ID, err)
+		}
+		doc.Absences = append(doc.Absences, absences...)
+
+		holidays, err := App.store.ListHolidays(team.ID)
+		if err != nil {
+			return doc, fmt.Errorf("could not list holidays of team %s: %w", team.ID, err)
+		}
+		doc.Holidays = append(doc.Holidays, holidays...)
+
+		templates, err := App.store.ListTemplates(team.ID)
+		if err != nil {
+			return doc, fmt.Errorf("could not list templates of team %s: %w", team.ID, err)
+		}
+		doc.Templates = append(doc.Templates, templates...)
+
+		settings, err := App.store.ListUserSettings(team.ID)
+		if err != nil {
+			return doc, fmt.Errorf("could not list user settings of team %s: %w", team.ID, err)
+		}
+		doc.UserSettings = append(doc.UserSettings, settings...)
+	}
+
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return doc, err
+	}
+	for _, question := range questions {
+		if (opts.TeamID != "" && question.TeamID != opts.TeamID) || (opts.Channel != "" && question.Channel != opts.Channel) {
+			continue
+		}
+		doc.Questions = append(doc.Questions, question)
+
+		instances, err := App.store.ListQuestionInstances(question.ID, time.Time{})
+		if err != nil {
+			return doc, err
+		}
+		doc.Instances = append(doc.Instances, instances...)
+	}
+
+	return doc, nil
+}
+
+type ImportOptions struct {
+	DryRun   bool
+	Inactive bool              // import all questions as inactive
+	Teams    map[string]string // team ID remapping, old to new
+	Channels map[string]string // channel ID remapping, old to new
+}
+
+type ImportReport struct {
+	TeamsCreated      int
+	AbsencesCreated   int
+	HolidaysCreated   int
+	TemplatesCreated  int
+	SettingsCreated   int
+	QuestionsCreated  int
+	InstancesCreated  int
+	Conflicts         []string
+	Warnings          []string
+	QuestionIDMapping map[uint64]uint64 // old question ID to the new one, 0 in a dry run
+}
+
+func remap(mapping map[string]string, id string) string {
+	if mapped, ok := mapping[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// ImportData merges an exported document into the database. Questions always get new IDs,
+// objects which already exist are reported as conflicts and skipped.
+func ImportData(doc Export, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{QuestionIDMapping: map[uint64]uint64{}}
+
+	if doc.Version < 1 || doc.Version > exportVersion {
+		return report, fmt.Errorf("unsupported export version %d", doc.Version)
+	}
+
+	if doc.Version < 3 {
+		for i := range doc.Questions {
+			doc.Questions[i].inheritZeroRetention()
+		}
+		for i := range doc.Templates {
+			doc.Templates[i].Question.inheritZeroRetention()
+		}
+	}
+
+	existingTeams, err := App.store.ListTeams()
+	if err != nil {
+		return report, err
+	}
+	teamExists := map[string]bool{}
+	for _, team := range existingTeams {
+		teamExists[team.ID] = true
+	}
+
+	for _, team := range doc.Teams {
+		if team.SealedToken != nil {
+			// tokens are bound to the exported team ID, so they are decrypted before remapping
+			team.Token, err = team.SealedToken.open(App.config.TokenKey, team.ID)
+			if err != nil {
+				return report, fmt.Errorf("could not decrypt token of team %s: %w", team.ID, err)
+			}
+			team.SealedToken = nil
+		}
+		team.ID = remap(opts.Teams, team.ID)
+		if teamExists[team.ID] {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("team %s already exists, keeping the existing one", team.ID))
+			continue
+		}
+		if team.Token == "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("team %s has no token, the app has to be installed to it again", team.ID))
+		}
+
+		report.TeamsCreated++
+		if !opts.DryRun {
+			err = team.Save()
+			if err != nil {
+				return report, fmt.Errorf("could not save team %s: %w", team.ID, err)
+			}
+		}
+	}
+
+	existingAbsences := map[string][]Absence{}
+	for _, absence := range doc.Absences {
+		absence.ID = 0
+		absence.TeamID = remap(opts.Teams, absence.TeamID)
+		if _, ok := existingAbsences[absence.TeamID]; !ok {
+			existingAbsences[absence.TeamID], err = App.store.ListAbsences(absence.TeamID)
+			if err != nil {
+				return report, err
+			}
+		}
+
+		if slices.ContainsFunc(existingAbsences[absence.TeamID], func(existing Absence) bool {
+			return existing.User == absence.User && existing.From == absence.From && existing.To == absence.To
+		}) {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("absence of %s from %s to %s already exists, skipping it", absence.User, absence.From, absence.To))
+			continue
+		}
+
+		report.AbsencesCreated++
+		if !opts.DryRun {
+			err = App.store.SaveAbsence(&absence)
+			if err != nil {
+				return report, fmt.Errorf("could not save absence of %s: %w", absence.User, err)
+			}
+		}
+	}
+
+	existingHolidays := map[string][]Holiday{}
+	for _, holiday := range doc.Holidays {
+		holiday.ID = 0
+		holiday.TeamID = remap(opts.Teams, holiday.TeamID)
+		if _, ok := existingHolidays[holiday.TeamID]; !ok {
+			existingHolidays[holiday.TeamID], err = App.store.ListHolidays(holiday.TeamID)
+			if err != nil {
+				return report, err
+			}
+		}
+
+		if slices.ContainsFunc(existingHolidays[holiday.TeamID], func(existing Holiday) bool { return existing.Date == holiday.Date }) {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("holiday on %s already exists in team %s, skipping it", holiday.Date, holiday.TeamID))
+			continue
+		}
+
+		report.HolidaysCreated++
+		if !opts.DryRun {
+			err = App.store.SaveHoliday(&holiday)
+			if err != nil {
+				return report, fmt.Errorf("could not save holiday %s: %w", holiday.Date, err)
+			}
+		}
+	}
+
+	existingTemplates := map[string][]QuestionTemplate{}
+	for _, template := range doc.Templates {
+		template.ID = 0
+		template.TeamID = remap(opts.Teams, template.TeamID)
+		if _, ok := existingTemplates[template.TeamID]; !ok {
+			existingTemplates[template.TeamID], err = App.store.ListTemplates(template.TeamID)
+			if err != nil {
+				return report, err
+			}
+		}
+
+		if slices.ContainsFunc(existingTemplates[template.TeamID], func(existing QuestionTemplate) bool { return existing.Name == template.Name }) {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("template %q already exists in team %s, skipping it", template.Name, template.TeamID))
+			continue
+		}
+
+		report.TemplatesCreated++
+		if !opts.DryRun {
+			err = App.store.SaveTemplate(&template)
+			if err != nil {
+				return report, fmt.Errorf("could not save template %q: %w", template.Name, err)
+			}
+		}
+	}
+
+	for _, settings := range doc.UserSettings {
+		settings.TeamID = remap(opts.Teams, settings.TeamID)
+		existing, err := App.store.LoadUserSettings(settings.TeamID, settings.User)
+		if err != nil {
+			return report, err
+		}
+		if existing.User != "" {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("settings of %s already exist in team %s, keeping the existing ones", settings.User, settings.TeamID))
+			continue
+		}
+
+		report.SettingsCreated++
+		if !opts.DryRun {
+			err = settings.Save()
+			if err != nil {
+				return report, fmt.Errorf("could not save settings of %s: %w", settings.User, err)
+			}
+		}
+	}
+
+	existingQuestions, err := App.store.ListQuestions()
+	if err != nil {
+		return report, err
+	}
+
+	questions := map[uint64]*Question{}
+	moved := map[uint64]bool{} // questions imported into another team or channel, by their old ID
+	for _, question := range doc.Questions {
+		oldID := question.ID
+		question.ID = 0
+		teamID, channel := question.TeamID, question.Channel
+		question.TeamID = remap(opts.Teams, question.TeamID)
+		question.Channel = remap(opts.Channels, question.Channel)
+		if question.TeamID != teamID || question.Channel != channel {
+			// the messages of its rounds stay in the old channel, so none of them can continue
+			moved[oldID] = true
+			question.CurrentInstance = ""
+		}
+		if opts.Inactive {
+			question.IsActive = false
+		}
+
+		duplicate := false
+		for _, existing := range existingQuestions {
+			if existing.TeamID == question.TeamID && existing.Channel == question.Channel &&
+				existing.Message == question.Message && existing.Cron == question.Cron {
+				report.Conflicts = append(report.Conflicts, fmt.Sprintf("question %d is the same as existing question %d, skipping it and its instances", oldID, existing.ID))
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		report.QuestionsCreated++
+		if !opts.DryRun {
+			err = question.Save()
+			if err != nil {
+				return report, fmt.Errorf("could not save question %d: %w", oldID, err)
+			}
+		}
+		report.QuestionIDMapping[oldID] = question.ID
+		questions[oldID] = &question
+	}
+
+	for _, qi := range doc.Instances {
+		question, ok := questions[qi.QuestionID]
+		if !ok {
+			continue
+		}
+
+		existing, err := App.store.LoadInstance(question.Channel, qi.Timestamp)
+		if err != nil {
+			return report, err
+		}
+		if existing.QuestionID != 0 {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("instance %s in %s already exists, skipping it", qi.Timestamp, question.Channel))
+			continue
+		}
+
+		qi.Question = question
+		if moved[qi.QuestionID] && qi.IsOpen() {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("instance %s of question %d was moved to %s, importing it closed", qi.Timestamp, qi.QuestionID, question.Channel))
+			qi.finish(StatusClosed)
+		}
+
+		report.InstancesCreated++
+		if !opts.DryRun {
+			qi.QuestionID = question.ID
+			err = qi.Save()
+			if err != nil {
+				return report, fmt.Errorf("could not save instance %s: %w", qi.Timestamp, err)
+			}
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestStore makes a new migrated database the store of the application for the rest of the test.
+func useTestStore(t *testing.T, driver string) {
+	t.Helper()
+
+	store := App.store
+	t.Cleanup(func() { App.store = store })
+	App.store = openTestStore(t, driver)
+}
+
+// exportTestData fills the store of the application with a team, its data and a question
+// with a closed and an open round, and exports it.
+func exportTestData(t *testing.T) Export {
+	t.Helper()
+
+	team := Team{ID: "T1", Name: "Trojsten", Token: "xoxb-secret"}
+	err := team.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = App.store.SaveAbsence(&Absence{TeamID: "T1", User: "U2", From: "2026-03-02", To: "2026-03-06"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = App.store.SaveHoliday(&Holiday{TeamID: "T1", Date: "2026-12-24", Name: "Štedrý deň"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = App.store.SaveTemplate(&QuestionTemplate{TeamID: "T1", Name: "Retro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = App.store.SaveUserSettings(&UserSettings{TeamID: "T1", User: "U1", MuteReminders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posted := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	q := Question{TeamID: "T1", Channel: "C1", Message: "Ako sa darí?", Cron: "0 9 * * *", IsActive: true,
+		CurrentInstance: slackTimestamp(posted.AddDate(0, 0, 1))}
+	err = q.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for day, status := range []InstanceStatus{StatusClosed, StatusOpen} {
+		qi := QuestionInstance{
+			Question:   &q,
+			QuestionID: q.ID,
+			Timestamp:  slackTimestamp(posted.AddDate(0, 0, day)),
+			Status:     status,
+			Responses:  map[string]ResponseStatus{"U1": ResponseAnswered, "U2": ResponseMissing},
+		}
+		err = qi.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	doc, err := ExportData(ExportOptions{IncludeTokens: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exports are written to a file, so the round trip goes through JSON
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Export
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestExportImport(t *testing.T) {
+	config := App.config
+	t.Cleanup(func() { App.config = config })
+	App.config.TokenKey = testKey(1)
+
+	tests := []struct {
+		name        string
+		opts        ImportOptions
+		wantTeam    string
+		wantChannel string
+		wantOpen    bool
+	}{
+		{"same team", ImportOptions{}, "T1", "C1", true},
+		{"remapped team and channel", ImportOptions{Teams: map[string]string{"T1": "T2"}, Channels: map[string]string{"C1": "C2"}}, "T2", "C2", false},
+		{"remapped channel", ImportOptions{Channels: map[string]string{"C1": "C2"}}, "T1", "C2", false},
+		{"dry run", ImportOptions{DryRun: true, Teams: map[string]string{"T1": "T2"}, Channels: map[string]string{"C1": "C2"}}, "T2", "C2", false},
+	}
+	for _, driver := range storeDrivers {
+		for _, tt := range tests {
+			t.Run(driver+" "+tt.name, func(t *testing.T) {
+				useTestStore(t, driver)
+				doc := exportTestData(t)
+				if doc.Teams[0].Token != "" || doc.Teams[0].SealedToken == nil {
+					t.Fatalf("exported team %+v, want only a sealed token", doc.Teams[0])
+				}
+
+				useTestStore(t, driver)
+				report, err := ImportData(doc, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				created := []int{report.TeamsCreated, report.AbsencesCreated, report.HolidaysCreated, report.TemplatesCreated,
+					report.SettingsCreated, report.QuestionsCreated, report.InstancesCreated}
+				if !slices.Equal(created, []int{1, 1, 1, 1, 1, 1, 2}) || len(report.Conflicts) != 0 {
+					t.Errorf("ImportData() = %+v, want everything created", report)
+				}
+				moved := slices.ContainsFunc(report.Warnings, func(warning string) bool { return strings.Contains(warning, "importing it closed") })
+				if moved == tt.wantOpen {
+					t.Errorf("warnings %v, want a warning about closing the open round: %v", report.Warnings, !tt.wantOpen)
+				}
+
+				questions, err := App.store.ListQuestions()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.opts.DryRun {
+					if len(questions) != 0 {
+						t.Errorf("dry run imported %d questions", len(questions))
+					}
+					return
+				}
+
+				teams, err := ListTeams()
+				if err != nil || len(teams) != 1 || teams[0].ID != tt.wantTeam || teams[0].Token != "xoxb-secret" {
+					t.Errorf("ListTeams() = %+v, %v, want team %s with its token", teams, err, tt.wantTeam)
+				}
+				absences, err := App.store.ListAbsences(tt.wantTeam)
+				if err != nil || len(absences) != 1 {
+					t.Errorf("ListAbsences(%s) = %+v, %v, want the absence", tt.wantTeam, absences, err)
+				}
+				settings, err := App.store.LoadUserSettings(tt.wantTeam, "U1")
+				if err != nil || !settings.MuteReminders {
+					t.Errorf("LoadUserSettings(%s) = %+v, %v, want the settings", tt.wantTeam, settings, err)
+				}
+
+				if len(questions) != 1 {
+					t.Fatalf("imported %d questions, want 1", len(questions))
+				}
+				q := questions[0]
+				if q.TeamID != tt.wantTeam || q.Channel != tt.wantChannel || q.ID != report.QuestionIDMapping[doc.Questions[0].ID] {
+					t.Errorf("imported question %+v, want it in %s/%s", q, tt.wantTeam, tt.wantChannel)
+				}
+				if (q.CurrentInstance != "") != tt.wantOpen {
+					t.Errorf("current instance of the imported question = %q, want it kept: %v", q.CurrentInstance, tt.wantOpen)
+				}
+
+				instances, err := App.store.ListQuestionInstances(q.ID, time.Time{})
+				if err != nil || len(instances) != 2 {
+					t.Fatalf("ListQuestionInstances() = %+v, %v, want 2 instances", instances, err)
+				}
+				last := instances[1]
+				if last.IsOpen() != tt.wantOpen {
+					t.Errorf("last round is open: %v, want %v", last.IsOpen(), tt.wantOpen)
+				}
+				if !tt.wantOpen && (last.Tally == nil || *last.Tally != (Tally{Answered: 1, Missing: 1})) {
+					t.Errorf("tally of the closed round = %+v, want 1 answered and 1 missing", last.Tally)
+				}
+
+				open, err := App.store.ListOpenInstances()
+				if err != nil || (len(open) == 1) != tt.wantOpen {
+					t.Errorf("ListOpenInstances() = %d instances, %v, want the last one open: %v", len(open), err, tt.wantOpen)
+				}
+
+				report, err = ImportData(doc, tt.opts)
+				if err != nil || report.QuestionsCreated != 0 || report.TeamsCreated != 0 || len(report.Conflicts) == 0 {
+					t.Errorf("importing again = %+v, %v, want only conflicts", report, err)
+				}
+			})
+		}
+	}
+}
+
+func TestImportWrongKey(t *testing.T) {
+	config := App.config
+	t.Cleanup(func() { App.config = config })
+	App.config.TokenKey = testKey(1)
+
+	useTestStore(t, DriverBolt)
+	doc := exportTestData(t)
+
+	useTestStore(t, DriverBolt)
+	App.config.TokenKey = testKey(2)
+	_, err := ImportData(doc, ImportOptions{})
+	if err == nil {
+		t.Error("importing a token sealed with another key succeeded")
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Holiday is a day off in the team, scheduled rounds of questions which opt in are skipped or shifted.
+type Holiday struct {
+	ID     uint64 // holiday identifier, unique within the team
+	TeamID string // slack team identifier
+	Date   string // YYYY-MM-DD
+	Name   string
+}
+
+type HolidayPolicy string
+
+const (
+	HolidaysIgnore HolidayPolicy = ""      // run on holidays as usual
+	HolidaysSkip   HolidayPolicy = "skip"  // do not run on holidays
+	HolidaysShift  HolidayPolicy = "shift" // run on the next working day instead
+)
+
+// SkippedRun is a scheduled run which did not happen on its day because of a holiday.
+type SkippedRun struct {
+	Date    string    // YYYY-MM-DD in the time zone of the question
+	Holiday string    // name of the holiday
+	Shifted time.Time // when the run was moved to, zero if it was skipped
+}
+
+// maxSkippedRuns is how many skipped runs are kept on a question.
+const maxSkippedRuns = 10
+
+func (q *Question) logSkippedRun(run SkippedRun) {
+	q.SkippedRuns = append(q.SkippedRuns, run)
+	if len(q.SkippedRuns) > maxSkippedRuns {
+		q.SkippedRuns = q.SkippedRuns[len(q.SkippedRuns)-maxSkippedRuns:]
+	}
+}
+
+// skipHoliday checks whether the run due now falls on a holiday of the team. If it does, the run
+// is logged as skipped and shifted according to the holiday policy, and true is returned.
+func (q *Question) skipHoliday(now time.Time) (bool, error) {
+	if q.HolidayPolicy == HolidaysIgnore {
+		return false, nil
+	}
+
+	holidays, err := App.store.ListHolidays(q.TeamID)
+	if err != nil {
+		return false, err
+	}
+
+	local := now.In(q.location())
+	holiday := findHoliday(holidays, local.Format(time.DateOnly))
+	if holiday == nil {
+		return false, nil
+	}
+
+	run := SkippedRun{Date: local.Format(time.DateOnly), Holiday: holiday.Name}
+	if q.HolidayPolicy == HolidaysShift {
+		run.Shifted = nextWorkingDay(holidays, local)
+		q.ShiftedRun = run.Shifted
+	}
+	q.logSkippedRun(run)
+	return true, q.Save()
+}
+
+// findHoliday returns the holiday on the given day, or nil if it is a regular day.
+func findHoliday(holidays []Holiday, day string) *Holiday {
+	index := slices.IndexFunc(holidays, func(h Holiday) bool {
+		return h.Date == day
+	})
+	if index == -1 {
+		return nil
+	}
+	return &holidays[index]
+}
+
+// nextWorkingDay returns the same local time on the first following weekday which is not a holiday.
+func nextWorkingDay(holidays []Holiday, t time.Time) time.Time {
+	for {
+		t = t.AddDate(0, 0, 1)
+		if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+			continue
+		}
+		if findHoliday(holidays, t.Format(time.DateOnly)) == nil {
+			return t
+		}
+	}
+}
+
+// AddHolidays stores the holidays of the team, skipping days which already have one.
+// It returns how many holidays were added.
+func AddHolidays(teamID string, holidays []Holiday) (int, error) {
+	existing, err := App.store.ListHolidays(teamID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, holiday := range holidays {
+		if findHoliday(existing, holiday.Date) != nil {
+			continue
+		}
+
+		holiday.ID = 0
+		holiday.TeamID = teamID
+		err = App.store.SaveHoliday(&holiday)
+		if err != nil {
+			return count, err
+		}
+		existing = append(existing, holiday)
+		count++
+	}
+	return count, nil
+}
+
+// recurrenceYears is how many years ahead yearly recurring events are expanded.
+const recurrenceYears = 5
+
+// ParseICS reads all-day events from an iCalendar file, multi-day events produce one holiday per day. Timed
+// events such as meetings are skipped, they don't make the whole day a holiday. Yearly recurring events are
+// expanded from the current year until recurrenceYears ahead, other recurrences are rejected.
+func ParseICS(r io.Reader) ([]Holiday, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+	until := now.AddDate(recurrenceYears, 0, 0).Format(time.DateOnly)
+
+	var holidays []Holiday
+	var event map[string]string
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// drop parameters such as DTSTART;VALUE=DATE
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = map[string]string{}
+		case name == "END" && value == "VEVENT":
+			if event == nil || !allDay(event) {
+				event = nil
+				continue
+			}
+			days, err := eventDays(event)
+			if err != nil {
+				return nil, err
+			}
+			days, err = recurringDays(event, days, from, until)
+			if err != nil {
+				return nil, err
+			}
+			for _, day := range days {
+				holidays = append(holidays, Holiday{Date: day, Name: event["SUMMARY"]})
+			}
+			event = nil
+		case event != nil:
+			event[name] = unescapeICS(value)
+		}
+	}
+
+	return holidays, nil
+}
+
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func unescapeICS(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// allDay reports whether the event starts on a date rather than at a time of day.
+func allDay(event map[string]string) bool {
+	return len(event["DTSTART"]) <= len("20060102")
+}
+
+// eventDays returns all days covered by an all-day event. The end of the event is exclusive.
+func eventDays(event map[string]string) ([]string, error) {
+	start, err := parseICSDate(event["DTSTART"])
+	if err != nil {
+		return nil, fmt.Errorf("event %q: %w", event["SUMMARY"], err)
+	}
+
+	end := start.AddDate(0, 0, 1)
+	if event["DTEND"] != "" {
+		end, err = parseICSDate(event["DTEND"])
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", event["SUMMARY"], err)
+		}
+	}
+
+	var days []string
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(time.DateOnly))
+	}
+	if len(days) == 0 {
+		days = append(days, start.Format(time.DateOnly))
+	}
+	return days, nil
+}
+
+// recurringDays repeats the days of a yearly recurring event, keeping occurrences which start between
+// from and until. Events without a recurrence rule are returned as they are.
+func recurringDays(event map[string]string, days []string, from, until string) ([]string, error) {
+	if event["RRULE"] == "" {
+		return days, nil
+	}
+
+	yearly, supported, interval, count := false, true, 1, 0
+	for _, part := range strings.Split(event["RRULE"], ";") {
+		key, value, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			yearly = strings.ToUpper(value) == "YEARLY"
+		case "INTERVAL":
+			interval, err = strconv.Atoi(value)
+			if err == nil && interval < 1 {
+				err = fmt.Errorf("interval must be positive")
+			}
+		case "COUNT":
+			count, err = strconv.Atoi(value)
+			if err == nil && count < 1 {
+				err = fmt.Errorf("count must be positive")
+			}
+		case "UNTIL":
+			var end time.Time
+			end, err = parseICSDate(value)
+			if end.Format(time.DateOnly) < until {
+				until = end.Format(time.DateOnly)
+			}
+		default:
+			// BYDAY, BYMONTH and others would need a full recurrence engine
+			supported = false
+		}
+		if err != nil {
+			return nil, fmt.Errorf("event %q: invalid recurrence %q: %w", event["SUMMARY"], event["RRULE"], err)
+		}
+	}
+	if !yearly || !supported {
+		return nil, fmt.Errorf("event %q: unsupported recurrence %q, only simple yearly events are supported", event["SUMMARY"], event["RRULE"])
+	}
+
+	first, err := time.Parse(time.DateOnly, days[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var recurring []string
+	for years, n := 0, 0; count == 0 || n < count; years += interval {
+		start := first.AddDate(years, 0, 0)
+		if start.Format(time.DateOnly) > until {
+			break
+		}
+		if start.Day() != first.Day() {
+			// February 29 only recurs in leap years
+			continue
+		}
+		n++
+		if start.Format(time.DateOnly) < from {
+			continue
+		}
+
+		for _, day := range days {
+			date, err := time.Parse(time.DateOnly, day)
+			if err != nil {
+				return nil, err
+			}
+			recurring = append(recurring, date.AddDate(years, 0, 0).Format(time.DateOnly))
+		}
+	}
+	return recurring, nil
+}
+
+// parseICSDate parses the date part of a DATE or DATE-TIME value.
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < len("20060102") {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return time.Parse("20060102", value[:len("20060102")])
+}
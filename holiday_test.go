@@ -0,0 +1,122 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEventDays(t *testing.T) {
+	tests := []struct {
+		name  string
+		event map[string]string
+		want  []string
+	}{
+		{"all-day without end", map[string]string{"DTSTART": "20261224"}, []string{"2026-12-24"}},
+		{"all-day end is exclusive", map[string]string{"DTSTART": "20261224", "DTEND": "20261227"},
+			[]string{"2026-12-24", "2026-12-25", "2026-12-26"}},
+		{"all-day across a year", map[string]string{"DTSTART": "20261231", "DTEND": "20270102"},
+			[]string{"2026-12-31", "2027-01-01"}},
+		{"end equal to start", map[string]string{"DTSTART": "20261224", "DTEND": "20261224"}, []string{"2026-12-24"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := eventDays(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("eventDays(%v) = %v, want %v", tt.event, got, tt.want)
+			}
+		})
+	}
+
+	_, err := eventDays(map[string]string{"DTSTART": "2026"})
+	if err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
+
+func TestRecurringDays(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		days    []string
+		want    []string
+		wantErr bool
+	}{
+		{"no rule", "", []string{"2020-01-01"}, []string{"2020-01-01"}, false},
+		{"yearly from the past", "FREQ=YEARLY", []string{"2020-01-01"},
+			[]string{"2026-01-01", "2027-01-01", "2028-01-01"}, false},
+		{"multi-day", "FREQ=YEARLY", []string{"2026-12-24", "2026-12-25"},
+			[]string{"2026-12-24", "2026-12-25", "2027-12-24", "2027-12-25", "2028-12-24", "2028-12-25"}, false},
+		{"interval", "FREQ=YEARLY;INTERVAL=2", []string{"2026-05-01"}, []string{"2026-05-01", "2028-05-01"}, false},
+		{"count includes past occurrences", "FREQ=YEARLY;COUNT=8", []string{"2020-01-01"},
+			[]string{"2026-01-01", "2027-01-01"}, false},
+		{"until", "FREQ=YEARLY;UNTIL=20270101", []string{"2020-01-01"}, []string{"2026-01-01", "2027-01-01"}, false},
+		{"leap day", "FREQ=YEARLY", []string{"2024-02-29"}, []string{"2028-02-29"}, false},
+		{"order of parts", "INTERVAL=1;FREQ=YEARLY", []string{"2026-05-01"},
+			[]string{"2026-05-01", "2027-05-01", "2028-05-01"}, false},
+		{"weekly", "FREQ=WEEKLY", []string{"2026-05-01"}, nil, true},
+		{"yearly by weekday", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", []string{"2026-11-26"}, nil, true},
+		{"missing frequency", "COUNT=3", []string{"2026-05-01"}, nil, true},
+		{"invalid count", "FREQ=YEARLY;COUNT=x", []string{"2026-05-01"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := map[string]string{"RRULE": tt.rule, "SUMMARY": "Sviatok"}
+			got, err := recurringDays(event, tt.days, "2026-01-01", "2028-12-31")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("recurringDays(%q) error = %v, want error %v", tt.rule, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("recurringDays(%q, %v) = %v, want %v", tt.rule, tt.days, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20260901",
+		"SUMMARY:Deň Ústavy",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20261224",
+		"DTEND;VALUE=DATE:20261226",
+		"SUMMARY:Vianoce\\, sviatky",
+		"  pokoja",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;TZID=Europe/Bratislava:20261110T100000",
+		"DTEND;TZID=Europe/Bratislava:20261110T110000",
+		"SUMMARY:Porada",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20261111T230000Z",
+		"DTEND:20261112T010000Z",
+		"SUMMARY:Nasadenie",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	got, err := ParseICS(strings.NewReader(ics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Holiday{
+		{Date: "2026-09-01", Name: "Deň Ústavy"},
+		{Date: "2026-12-24", Name: "Vianoce, sviatky pokoja"},
+		{Date: "2026-12-25", Name: "Vianoce, sviatky pokoja"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseICS() = %v, want %v", got, want)
+	}
+
+	_, err = ParseICS(strings.NewReader("BEGIN:VEVENT\r\nDTSTART:20260105\r\nRRULE:FREQ=WEEKLY\r\nEND:VEVENT\r\n"))
+	if err == nil {
+		t.Error("expected an error for a weekly event")
+	}
+}
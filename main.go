@@ -18,7 +18,16 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 
-	err = OpenDatabase(App.config.DatabaseFile)
+	if len(os.Args) > 1 {
+		err = RunCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			log.Error("Command failed.", "command", os.Args[1], "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = OpenDatabase(App.config.DatabaseDriver, App.config.DatabaseFile)
 	if err != nil {
 		log.Error("Could not open database.", "err", err)
 		os.Exit(1)
@@ -1,53 +1,62 @@
 package main
 
 import (
-	"encoding/json"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/slack-go/slack"
-	bolt "go.etcd.io/bbolt"
 )
 
 const manualCheckCron string = "25 * * * *"
 
 func CheckAllThreads() error {
-	var instances []QuestionInstance
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return err
+	}
 
-	err := App.db.View(func(tx *bolt.Tx) error {
-		return tx.Bucket([]byte("messages")).ForEach(func(k, v []byte) error {
-			var qi QuestionInstance
-			err := json.Unmarshal(v, &qi)
-			if err != nil {
-				return err
-			}
+	for _, question := range questions {
+		if question.IsDeleted() {
+			continue
+		}
 
-			err = qi.LoadQuestion()
-			if err != nil {
-				return err
-			}
+		err = checkQuestionThreads(&question)
+		if err != nil {
+			return err
+		}
+	}
 
-			instances = append(instances, qi)
-			return nil
-		})
-	})
+	return nil
+}
+
+func checkQuestionThreads(question *Question) error {
+	var since time.Time
+	if days := question.pollDays(); days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	instances, err := App.store.ListQuestionInstances(question.ID, since)
 	if err != nil {
 		return err
 	}
 
 	for _, inst := range instances {
-		err = inst.CheckNewMessages()
+		inst.Question = question
+		err = updateInstance(question.Channel, inst.Timestamp, question, func(qi *QuestionInstance) error {
+			return qi.CheckNewMessages()
+		})
 		slackErr, ok := err.(slack.SlackErrorResponse)
 		if ok && (slackErr.Err == "not_in_channel" || slackErr.Err == "channel_not_found") {
 			if slackErr.Err == "not_in_channel" {
-				log.Info("I am no longer in the channel. Deleting question.", "question", inst.QuestionID, "channel", inst.Question.Channel)
+				log.Info("I am no longer in the channel. Moving question to trash.", "question", inst.QuestionID, "channel", inst.Question.Channel)
 			} else {
-				log.Info("Channel is archived or not found. Deleting question.", "question", inst.QuestionID, "channel", inst.Question.Channel)
+				log.Info("Channel is archived or not found. Moving question to trash.", "question", inst.QuestionID, "channel", inst.Question.Channel)
 			}
-			err := inst.Question.Delete()
+			err := inst.Question.Delete(DeletedChannelGone)
 			if err != nil {
 				log.Error("Could not delete question.", "question", inst.QuestionID, "err", err)
 			}
-			continue
+			return nil
 		}
 
 		if err != nil {
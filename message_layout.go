@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultLayout is the text/template of the question message used by questions without their own layout.
+const DefaultLayout = `👋 {{.Greeting}}
+
+{{quote .Question}}
+
+{{if .Facilitator}}🎤 Toto kolo vedie {{.Facilitator}}.
+
+{{end -}}
+{{if .Open -}}
+{{if .Missing -}}
+{{if .QuorumReached}}✨ Kvórum je splnené, odpovedalo {{len .Answered}} z {{.Expected}}. Ďalšie odpovede sú stále vítané.
+{{end -}}
+{{.Instruction}}
+❌: {{join .Missing}}
+✅: {{join .Answered}}
+{{- if .Deadline}}
+⏳ Termín: {{.Deadline}}
+{{- end}}
+{{- else -}}
+{{.Completion}}
+{{- end}}
+{{- else -}}
+🔒 _Toto kolo je uzavreté._
+{{- with .Tally}}
+📊 Včas odpovedalo {{.Answered}} z {{.Expected}}.
+{{- end}}
+{{- if and .QuorumReached (or .Missing .Late)}}
+✨ Kvórum bolo splnené.
+{{- end}}
+{{- if or .Missing .Late}}
+{{- if .Missing}}
+❌ Títo ľudia neodpovedali: {{join .Missing}}
+{{- end}}
+{{- if .Late}}
+⏰ Neskoro: {{join .Late}}
+{{- end}}
+✅: {{join .Answered}}
+{{- else}}
+{{.Completion}}
+{{- end}}
+{{- end}}
+{{- if .Skipped}}
+⏭️: {{join .Skipped}}
+{{- end}}
+{{- if .Excused}}
+🌴: {{join .Excused}}
+{{- end}}`
+
+// MessageData is what the layout of a question message can use.
+type MessageData struct {
+	Greeting    string   // greeting picked for the round
+	Question    string   // text of the question
+	Round       int      // number of the round, starting at 1
+	Open        bool     // whether the round is still running
+	Instruction string   // how to answer, depends on whether the question has prompts
+	Completion  string   // shown when nobody is missing
+	Facilitator string   // mention of the user leading the round, if any
+	Deadline    string   // when the round closes formatted by Slack, empty if it has no deadline
+	Answered    []string // mentions of users who answered in time
+	Missing     []string // mentions of users who did not answer yet
+	Late        []string // mentions of users who answered after the round was closed
+	Excused     []string // mentions of users who are out of office
+	Skipped     []string // mentions of users who skipped the round
+	Tally       *Tally   // final result of a closed round, nil if nobody was expected to answer
+
+	Expected      int  // how many users are expected to answer, excused users do not count
+	Quorum        int  // answers needed for the quorum, 0 if the question has none
+	QuorumReached bool // whether enough users answered in time
+}
+
+var layoutFuncs = template.FuncMap{
+	"join": func(users []string) string {
+		return strings.Join(users, ", ")
+	},
+	"quote": func(text string) string {
+		return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// maxMessageLength is how many characters Slack accepts in a message, rendering a longer one is aborted.
+const maxMessageLength = 40000
+
+// maxRangeDepth is how deeply ranges may be nested in a layout.
+const maxRangeDepth = 2
+
+// rangeFields are the fields of MessageData a layout may range over, all of them are lists of users.
+var rangeFields = []string{"Answered", "Missing", "Late", "Excused", "Skipped"}
+
+var errMessageTooLong = errors.New("message is too long")
+
+// limitedWriter fails once more than remaining characters are written to it, so that a layout producing
+// a huge message stops early.
+type limitedWriter struct {
+	strings.Builder
+	remaining int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	length := utf8.RuneCount(p)
+	if length > w.remaining {
+		return 0, errMessageTooLong
+	}
+	w.remaining -= length
+	return w.Builder.Write(p)
+}
+
+func parseLayout(layout string) (*template.Template, error) {
+	if layout == "" {
+		layout = DefaultLayout
+	}
+	tmpl, err := template.New("message").Funcs(layoutFuncs).Parse(layout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkLayoutNode(tmpl.Tree.Root, 0)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// checkLayoutNode rejects constructs which could make rendering the layout run for a very long time:
+// ranges over anything else than the lists of users, too deeply nested ranges and calls of templates,
+// which could recurse.
+func checkLayoutNode(node parse.Node, depth int) error {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, child := range node.Nodes {
+			err := checkLayoutNode(child, depth)
+			if err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkLayoutBranch(&node.BranchNode, depth)
+	case *parse.WithNode:
+		return checkLayoutBranch(&node.BranchNode, depth)
+	case *parse.RangeNode:
+		if depth >= maxRangeDepth {
+			return fmt.Errorf("ranges may be nested at most %d levels deep", maxRangeDepth)
+		}
+		if !rangesOverUsers(node.Pipe) {
+			return fmt.Errorf("range over %s is not allowed, only over %s", node.Pipe, strings.Join(rangeFields, ", "))
+		}
+		return checkLayoutBranch(&node.BranchNode, depth+1)
+	case *parse.TemplateNode:
+		return fmt.Errorf("calling templates is not allowed")
+	}
+	return nil
+}
+
+func checkLayoutBranch(node *parse.BranchNode, depth int) error {
+	err := checkLayoutNode(node.List, depth)
+	if err != nil {
+		return err
+	}
+	return checkLayoutNode(node.ElseList, depth)
+}
+
+// rangesOverUsers reports whether the pipeline is just one of rangeFields, such as .Missing or $.Missing.
+func rangesOverUsers(pipe *parse.PipeNode) bool {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+
+	var field []string
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		field = arg.Ident
+	case *parse.VariableNode:
+		if arg.Ident[0] != "$" {
+			return false
+		}
+		field = arg.Ident[1:]
+	}
+	return len(field) == 1 && slices.Contains(rangeFields, field[0])
+}
+
+// validateLayout checks that the layout can be rendered, both for an open and a closed round.
+func validateLayout(layout string) error {
+	tmpl, err := parseLayout(layout)
+	if err != nil {
+		return err
+	}
+
+	for _, open := range []bool{true, false} {
+		err = tmpl.Execute(&limitedWriter{remaining: maxMessageLength}, sampleMessageData(open))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sampleMessageData(open bool) MessageData {
+	data := MessageData{
+		Greeting:    Greetings[0],
+		Question:    "Ako sa darí?",
+		Round:       1,
+		Open:        open,
+		Instruction: "_Napíšte za seba update do threadu._",
+		Completion:  "🎉 Všetci už napísali svoj update, weeee!",
+		Answered:    []string{"<@U1>"},
+		Missing:     []string{"<@U2>"},
+		Excused:     []string{"<@U3>"},
+		Expected:    2,
+		Quorum:      1,
+	}
+	if !open {
+		data.Late = []string{"<@U4>"}
+		data.Tally = &Tally{Answered: 1, Missing: 2, Excused: 1, Quorum: 1}
+		data.Expected = 3
+	}
+	data.QuorumReached = len(data.Answered) >= data.Quorum
+	return data
+}
+
+// pickGreeting returns a random greeting of the question, or one of the default greetings.
+func (q *Question) pickGreeting() string {
+	greetings := Greetings
+	if len(q.Greetings) != 0 {
+		greetings = q.Greetings
+	}
+	return greetings[rand.Intn(len(greetings))]
+}
+
+// pickCompletion returns a random completion message of the question, empty if it uses the default ones.
+func (q *Question) pickCompletion() string {
+	if len(q.Completions) == 0 {
+		return ""
+	}
+	return q.Completions[rand.Intn(len(q.Completions))]
+}
+
+func (qi *QuestionInstance) messageData() MessageData {
+	data := MessageData{
+		Greeting:   qi.Greeting,
+		Question:   qi.Question.Message,
+		Round:      qi.Round,
+		Open:       qi.IsOpen(),
+		Completion: qi.Completion,
+	}
+
+	data.Instruction = "_Napíšte za seba update do threadu._"
+	if len(qi.Question.Prompts) != 0 {
+		data.Instruction = "_Vyplňte svoj update cez tlačidlo Odpovedať._"
+	}
+
+	if data.Completion == "" {
+		data.Completion = "🎉 Všetci napísali svoj update."
+		if data.Open {
+			data.Completion = "🎉 Všetci už napísali svoj update, weeee!"
+		}
+	}
+
+	if qi.Facilitator != "" {
+		data.Facilitator = fmt.Sprintf("<@%s>", qi.Facilitator)
+	}
+	if !qi.Deadline.IsZero() {
+		data.Deadline = fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>",
+			qi.Deadline.Unix(), qi.Deadline.In(qi.Question.location()).Format("2. 1. 2006 15:04"))
+	}
+	if qi.Tally != nil && qi.Tally.Expected() != 0 {
+		data.Tally = qi.Tally
+	}
+
+	// sorted, so that the lists do not change order with every update of the message
+	users := make([]string, 0, len(qi.Responses))
+	for user := range qi.Responses {
+		users = append(users, user)
+	}
+	slices.Sort(users)
+
+	for _, user := range users {
+		mention := fmt.Sprintf("<@%s>", user)
+		switch qi.Responses[user] {
+		case ResponseAnswered:
+			data.Answered = append(data.Answered, mention)
+		case ResponseNothing:
+			data.Answered = append(data.Answered, mention+" 🤷")
+		case ResponseSkipped:
+			data.Skipped = append(data.Skipped, mention)
+		case ResponseLate:
+			data.Late = append(data.Late, mention)
+		case ResponseExcused:
+			data.Excused = append(data.Excused, mention)
+		default:
+			data.Missing = append(data.Missing, mention)
+		}
+	}
+
+	data.Expected = len(data.Answered) + len(data.Missing) + len(data.Late)
+	data.Quorum = qi.Question.quorum(data.Expected)
+	if qi.Tally != nil {
+		data.Quorum = qi.Tally.Quorum
+	}
+	data.QuorumReached = data.Quorum != 0 && len(data.Answered) >= data.Quorum
+
+	return data
+}
+
+// renderMessage renders the message with the layout of the question. If a custom layout fails,
+// the message is rendered with the default layout and the error is returned as well.
+func (qi *QuestionInstance) renderMessage() (string, error) {
+	data := qi.messageData()
+
+	message, err := executeLayout(qi.Question.Layout, data)
+	if err != nil && qi.Question.Layout != "" {
+		fallback, _ := executeLayout("", data)
+		return fallback, err
+	}
+	return message, err
+}
+
+func executeLayout(layout string, data MessageData) (string, error) {
+	tmpl, err := parseLayout(layout)
+	if err != nil {
+		return "", err
+	}
+
+	message := limitedWriter{remaining: maxMessageLength}
+	err = tmpl.Execute(&message, data)
+	return message.String(), err
+}
+
+// nextRound returns the number of the round which is about to start.
+func (q *Question) nextRound() (int, error) {
+	if q.Rounds == 0 {
+		// questions created before rounds were numbered
+		instances, err := App.store.ListQuestionInstances(q.ID, time.Time{})
+		if err != nil {
+			return 0, err
+		}
+		q.Rounds = len(instances)
+	}
+	return q.Rounds + 1, nil
+}
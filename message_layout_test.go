@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"plain text", "Ahoj {{.Question}}", false},
+		{"range over users", "{{range .Missing}}{{.}} {{end}}", false},
+		{"range with variables", "{{range $i, $user := .Answered}}{{$i}}: {{$user}}{{end}}", false},
+		{"nested range", "{{range .Missing}}{{range $.Answered}}{{.}}{{end}}{{end}}", false},
+		{"range in a branch", "{{if .Open}}{{with .Tally}}{{.Answered}}{{else}}{{range .Late}}{{.}}{{end}}{{end}}{{end}}", false},
+		{"syntax error", "{{.Question", true},
+		{"unknown field", "{{.Nope}}", true},
+		{"range over a number", "{{range 1000000000}}{{end}}", true},
+		{"range over a variable", "{{$n := 1000000000}}{{range $n}}{{end}}", true},
+		{"range over dot", "{{with 1000000000}}{{range .}}{{end}}{{end}}", true},
+		{"range over a count", "{{range .Expected}}{{end}}", true},
+		{"range over a function", "{{range len .Question}}{{end}}", true},
+		{"range in else", "{{if .Open}}{{else}}{{range 5}}{{end}}{{end}}", true},
+		{"too deeply nested range", "{{range .Missing}}{{range $.Missing}}{{range $.Missing}}{{end}}{{end}}{{end}}", true},
+		{"template call", `{{define "x"}}{{range $.Missing}}{{template "x"}}{{end}}{{end}}{{template "x"}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLayout(tt.layout)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateLayout(%q) error = %v, want error %v", tt.layout, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteLayoutTooLong(t *testing.T) {
+	data := sampleMessageData(true)
+	data.Missing = nil
+	for i := range maxMessageLength / 10 {
+		data.Missing = append(data.Missing, fmt.Sprintf("<@U%07d>", i))
+	}
+
+	_, err := executeLayout("", data)
+	if !errors.Is(err, errMessageTooLong) {
+		t.Errorf("executeLayout() error = %v, want %v", err, errMessageTooLong)
+	}
+
+	data.Missing = data.Missing[:10]
+	_, err = executeLayout("", data)
+	if err != nil {
+		t.Errorf("executeLayout() error = %v for a short message", err)
+	}
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// migration is a single numbered step of the database schema.
+//
+// Every migration has to be implemented for all database drivers. Migrations work
+// with raw JSON documents instead of the application types, so that they keep working
+// after the types change.
+type migration struct {
+	Version int
+	Name    string
+	Bolt    func(tx *bolt.Tx) error
+	SQLite  func(tx *sql.Tx) error
+}
+
+// migrations must be ordered by version, which starts at 1 and has no gaps.
+// Never change a migration which has already been released, add a new one instead.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Bolt: func(tx *bolt.Tx) error {
+			for _, bucket := range []string{"messages", "questions", "teams", "sessions"} {
+				_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS questions (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					team_id TEXT NOT NULL,
+					channel TEXT NOT NULL,
+					data TEXT NOT NULL
+				);
+
+				CREATE TABLE IF NOT EXISTS instances (
+					channel TEXT NOT NULL,
+					ts TEXT NOT NULL,
+					question_id INTEGER NOT NULL REFERENCES questions (id) ON DELETE CASCADE,
+					data TEXT NOT NULL,
+					PRIMARY KEY (channel, ts)
+				);
+
+				CREATE TABLE IF NOT EXISTS teams (
+					id TEXT PRIMARY KEY,
+					data TEXT NOT NULL
+				);
+
+				CREATE TABLE IF NOT EXISTS sessions (
+					token TEXT PRIMARY KEY,
+					created_at INTEGER NOT NULL,
+					data TEXT NOT NULL
+				);
+			`)
+			return err
+		},
+	},
+	{
+		// questions created before multi-team support have no team, MIGRATE_TEAM says which one they belong to;
+		// without it they are left as they are and assigned once it is set, see assignQuestionsToTeam
+		Version: 2,
+		Name:    "assign questions to team",
+		Bolt: func(tx *bolt.Tx) error {
+			if App.config.MigrateToTeam == "" {
+				count := 0
+				err := tx.Bucket([]byte("questions")).ForEach(func(k, v []byte) error {
+					doc, err := decodeDocument(v)
+					if err == nil && (doc["TeamID"] == nil || doc["TeamID"] == "") {
+						count++
+					}
+					return err
+				})
+				if count > 0 {
+					log.Warn("Questions have no team, set MIGRATE_TEAM to assign them.", "count", count)
+				}
+				return err
+			}
+
+			return rewriteBoltBucket(tx, tx.Bucket([]byte("questions")), func(doc jsonDocument) error {
+				if doc["TeamID"] == nil || doc["TeamID"] == "" {
+					doc["TeamID"] = App.config.MigrateToTeam
+				}
+				return nil
+			})
+		},
+		SQLite: func(tx *sql.Tx) error {
+			var count int
+			err := tx.QueryRow("SELECT COUNT(*) FROM questions WHERE team_id = ''").Scan(&count)
+			if err != nil || count == 0 {
+				return err
+			}
+			if App.config.MigrateToTeam == "" {
+				log.Warn("Questions have no team, set MIGRATE_TEAM to assign them.", "count", count)
+				return nil
+			}
+
+			_, err = tx.Exec("UPDATE questions SET team_id = ?, data = json_set(data, '$.TeamID', ?) WHERE team_id = ''",
+				App.config.MigrateToTeam, App.config.MigrateToTeam)
+			return err
+		},
+	},
+	{
+		// instances are moved from the flat messages bucket into a nested bucket per question,
+		// threads maps channel:timestamp to the question and open_instances maps questions to their current instance
+		Version: 3,
+		Name:    "index instances by question",
+		Bolt: func(tx *bolt.Tx) error {
+			instances, err := tx.CreateBucketIfNotExists([]byte("instances"))
+			if err != nil {
+				return err
+			}
+			threads, err := tx.CreateBucketIfNotExists([]byte("threads"))
+			if err != nil {
+				return err
+			}
+			openInstances, err := tx.CreateBucketIfNotExists([]byte("open_instances"))
+			if err != nil {
+				return err
+			}
+
+			questions := tx.Bucket([]byte("questions"))
+			err = questions.ForEach(func(k, v []byte) error {
+				doc, err := decodeDocument(v)
+				if err != nil {
+					return err
+				}
+
+				current, _ := doc["CurrentInstance"].(string)
+				if current == "" {
+					return nil
+				}
+				return openInstances.Put(k, []byte(current))
+			})
+			if err != nil {
+				return err
+			}
+
+			messages := tx.Bucket([]byte("messages"))
+			err = messages.ForEach(func(k, v []byte) error {
+				channel, timestamp, ok := strings.Cut(string(k), ":")
+				if !ok {
+					return fmt.Errorf("invalid message key %s", k)
+				}
+
+				doc, err := decodeDocument(v)
+				if err != nil {
+					return err
+				}
+
+				questionID := []byte(fmt.Sprint(doc["QuestionID"]))
+				if questions.Get(questionID) == nil {
+					log.Warn("Dropping instance of a deleted question.", "question", string(questionID), "channel", channel, "ts", timestamp)
+					return nil
+				}
+
+				questionInstances, err := instances.CreateBucketIfNotExists(questionID)
+				if err != nil {
+					return err
+				}
+
+				// the messages bucket is deleted below, so its keys and values must not be
+				// referenced from other buckets, bolt only copies them when the transaction commits
+				err = questionInstances.Put([]byte(timestamp), bytes.Clone(v))
+				if err != nil {
+					return err
+				}
+				return threads.Put(bytes.Clone(k), questionID)
+			})
+			if err != nil {
+				return err
+			}
+
+			return tx.DeleteBucket([]byte("messages"))
+		},
+		SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec("CREATE INDEX IF NOT EXISTS instances_question ON instances (question_id, ts)")
+			return err
+		},
+	},
+	{
+		Version: 4,
+		Name:    "instance archive",
+		Bolt: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("archive"))
+			return err
+		},
+		SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS archived_instances (
+					channel TEXT NOT NULL,
+					ts TEXT NOT NULL,
+					question_id INTEGER NOT NULL REFERENCES questions (id) ON DELETE CASCADE,
+					data TEXT NOT NULL,
+					PRIMARY KEY (channel, ts)
+				);
+
+				CREATE INDEX IF NOT EXISTS archived_instances_question ON archived_instances (question_id, ts);
+			`)
+			return err
+		},
+	},
+	{
+		// the current instance of a question stays open, all older ones are closed;
+		// responses change from booleans to statuses
+		Version: 5,
+		Name:    "instance status",
+		Bolt: func(tx *bolt.Tx) error {
+			current := map[string]string{}
+			err := tx.Bucket([]byte("questions")).ForEach(func(k, v []byte) error {
+				doc, err := decodeDocument(v)
+				if err != nil {
+					return err
+				}
+				current[string(k)], _ = doc["CurrentInstance"].(string)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			instances := tx.Bucket([]byte("instances"))
+			openInstances := tx.Bucket([]byte("open_instances"))
+			return instances.ForEachBucket(func(questionID []byte) error {
+				err := openInstances.Delete(questionID)
+				if err != nil {
+					return err
+				}
+
+				return rewriteBoltBucket(tx, instances.Bucket(questionID), func(doc jsonDocument) error {
+					migrateInstanceStatus(doc, current[string(questionID)])
+					if doc["Status"] != "open" {
+						return nil
+					}
+
+					timestamp, ok := doc["Timestamp"].(string)
+					if !ok {
+						return fmt.Errorf("instance of question %s has no timestamp", questionID)
+					}
+					return openInstances.Put(questionID, []byte(timestamp))
+				})
+			})
+		},
+		SQLite: func(tx *sql.Tx) error {
+			current := map[string]string{}
+			rows, err := tx.Query("SELECT id, ifnull(json_extract(data, '$.CurrentInstance'), '') FROM questions")
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var id, ts string
+				err = rows.Scan(&id, &ts)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				current[id] = ts
+			}
+			rows.Close()
+
+			err = rewriteSQLiteTable(tx, "instances", func(doc jsonDocument) error {
+				migrateInstanceStatus(doc, current[fmt.Sprint(doc["QuestionID"])])
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX IF NOT EXISTS instances_open ON instances (json_extract(data, '$.Status')) WHERE json_extract(data, '$.Status') = 'open'")
+			return err
+		},
+	},
+	{
+		// absences has a nested bucket per team in bbolt
+		Version: 6,
+		Name:    "absences",
+		Bolt: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("absences"))
+			return err
+		},
+		SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS absences (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					team_id TEXT NOT NULL,
+					user_id TEXT NOT NULL,
+					data TEXT NOT NULL
+				);
+
+				CREATE INDEX IF NOT EXISTS absences_team ON absences (team_id, user_id);
+			`)
+			return err
+		},
+	},
+	{
+		// holidays has a nested bucket per team in bbolt
+		Version: 7,
+		Name:    "holidays",
+		Bolt: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("holidays"))
+			return err
+		},
+		SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS holidays (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					team_id TEXT NOT NULL,
+					day TEXT NOT NULL,
+					data TEXT NOT NULL
+				);
+
+				CREATE INDEX IF NOT EXISTS holidays_team ON holidays (team_id, day);
+			`)
+			return err
+		},
+	},
+	{
+		// templates has a nested bucket per team in bbolt
+		Version: 8,
+		Name:    "templates",
+		Bolt: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("templates"))
+			return err
+		},
+		SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS templates (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					team_id TEXT NOT NULL,
+					name TEXT NOT NULL,
+					data TEXT NOT NULL
+				);
+
+				CREATE INDEX IF NOT EXISTS templates_team ON templates (team_id, name);
+			`)
+			return err
+		},
+	},
+	{
+		// user_settings has a nested bucket per team in bbolt, keyed by user
+		Version: 9,
+		Name:    "user settings",
+		Bolt: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("user_settings"))
+			return err
+		},
+		SQLite: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS user_settings (
+					team_id TEXT NOT NULL,
+					user_id TEXT NOT NULL,
+					data TEXT NOT NULL,
+					PRIMARY KEY (team_id, user_id)
+				);
+			`)
+			return err
+		},
+	},
+	{
+		// retention overrides of 0 days meant the global default, now they mean forever and null means the default
+		Version: 10,
+		Name:    "nullable retention overrides",
+		Bolt: func(tx *bolt.Tx) error {
+			err := rewriteBoltBucket(tx, tx.Bucket([]byte("questions")), func(doc jsonDocument) error {
+				clearZeroRetention(doc)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			templates := tx.Bucket([]byte("templates"))
+			return templates.ForEach(func(teamID, v []byte) error {
+				return rewriteBoltBucket(tx, templates.Bucket(teamID), func(doc jsonDocument) error {
+					if question, ok := doc["Question"].(map[string]any); ok {
+						clearZeroRetention(question)
+					}
+					return nil
+				})
+			})
+		},
+		SQLite: func(tx *sql.Tx) error {
+			for _, field := range []string{"PollDays", "ArchiveDays"} {
+				_, err := tx.Exec(fmt.Sprintf("UPDATE questions SET data = json_remove(data, '$.%[1]s') WHERE json_extract(data, '$.%[1]s') = 0", field))
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(fmt.Sprintf("UPDATE templates SET data = json_remove(data, '$.Question.%[1]s') WHERE json_extract(data, '$.Question.%[1]s') = 0", field))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+func migrateInstanceStatus(doc jsonDocument, currentInstance string) {
+	answered, missing := 0, 0
+	responses, _ := doc["Responses"].(map[string]any)
+	for user, response := range responses {
+		switch response {
+		case true, "answered":
+			responses[user] = "answered"
+			answered++
+		default:
+			responses[user] = "missing"
+			missing++
+		}
+	}
+
+	if doc["Timestamp"] == currentInstance {
+		doc["Status"] = "open"
+		return
+	}
+
+	doc["Status"] = "closed"
+	doc["Tally"] = map[string]any{"Answered": answered, "Missing": missing}
+}
+
+func clearZeroRetention(doc map[string]any) {
+	for _, field := range []string{"PollDays", "ArchiveDays"} {
+		if doc[field] == json.Number("0") {
+			delete(doc, field)
+		}
+	}
+}
+
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// pendingMigrations returns the migrations which need to be applied to a database of the given version.
+func pendingMigrations(version int) ([]migration, error) {
+	latest := latestSchemaVersion()
+	if version > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than %d supported by this binary", version, latest)
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %q has version %d, expected %d", m.Name, m.Version, i+1)
+		}
+	}
+
+	return migrations[version:], nil
+}
+
+func logMigration(m migration) {
+	log.Info("Migrating database.", "version", m.Version, "migration", m.Name)
+}
+
+type jsonDocument map[string]any
+
+func decodeDocument(data []byte) (jsonDocument, error) {
+	var doc jsonDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep large integers such as IDs intact
+	decoder.UseNumber()
+	err := decoder.Decode(&doc)
+	return doc, err
+}
+
+// rewriteBoltBucket applies fn to every JSON document stored directly in the bucket.
+func rewriteBoltBucket(tx *bolt.Tx, bucket *bolt.Bucket, fn func(doc jsonDocument) error) error {
+	updated := map[string][]byte{}
+
+	err := bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			// nested bucket
+			return nil
+		}
+
+		doc, err := decodeDocument(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+
+		err = fn(doc)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		updated[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, v := range updated {
+		err = bucket.Put([]byte(k), v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteSQLiteTable applies fn to the JSON document in the data column of every row of the table.
+func rewriteSQLiteTable(tx *sql.Tx, table string, fn func(doc jsonDocument) error) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT rowid, data FROM %s", table))
+	if err != nil {
+		return err
+	}
+
+	updated := map[int64][]byte{}
+	for rows.Next() {
+		var rowid int64
+		var data []byte
+		err = rows.Scan(&rowid, &data)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		doc, err := decodeDocument(data)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("%s %d: %w", table, rowid, err)
+		}
+
+		err = fn(doc)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		updated[rowid], err = json.Marshal(doc)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for rowid, data := range updated {
+		_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET data = ? WHERE rowid = ?", table), data, rowid)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// createBaselineBolt creates a database in the shape written before the schema was versioned:
+// instances in the flat messages bucket keyed by channel:timestamp and responses as booleans.
+func createBaselineBolt(t *testing.T, filename string) {
+	t.Helper()
+
+	db, err := bolt.Open(filename, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	buckets := map[string]map[string]string{
+		"questions": {
+			"1": `{"ID":1,"TeamID":"","Channel":"C1","Message":"Ako sa darí?","Users":["U1","U2"],"Cron":"0 9 * * 1-5","CurrentInstance":"1700000200.000000","IsActive":true}`,
+		},
+		"messages": {
+			"C1:1700000100.000000": `{"QuestionID":1,"Timestamp":"1700000100.000000","LastMessage":"","Responses":{"U1":true,"U2":false},"Greeting":"Ahojte!"}`,
+			"C1:1700000200.000000": `{"QuestionID":1,"Timestamp":"1700000200.000000","LastMessage":"","Responses":{"U1":false,"U2":false},"Greeting":"Nazdar!"}`,
+			"C9:1700000300.000000": `{"QuestionID":7,"Timestamp":"1700000300.000000","LastMessage":"","Responses":{},"Greeting":"Nazdar!"}`,
+		},
+		"teams": {
+			"T1": `{"ID":"T1","Name":"Trojsten","Token":"xoxb-baseline"}`,
+		},
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for name, values := range buckets {
+			bucket, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for k, v := range values {
+				err = bucket.Put([]byte(k), []byte(v))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateBaselineBolt(t *testing.T) {
+	tests := []struct {
+		name     string
+		teams    []string // MIGRATE_TEAM of each start of the application
+		wantTeam string
+	}{
+		{"without team", []string{""}, ""},
+		{"with team", []string{"T1"}, "T1"},
+		{"team set later", []string{"", "T1"}, "T1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "buzerator.db")
+			createBaselineBolt(t, filename)
+
+			config, store := App.config, App.store
+			t.Cleanup(func() { App.config, App.store = config, store })
+			App.store = nil
+
+			for _, team := range tt.teams {
+				if App.store != nil {
+					App.store.Close()
+				}
+				App.config.MigrateToTeam = team
+				err := OpenDatabase(DriverBolt, filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Cleanup(func() { App.store.Close() })
+
+			version, err := App.store.SchemaVersion()
+			if err != nil || version != latestSchemaVersion() {
+				t.Errorf("SchemaVersion() = %d, %v, want %d", version, err, latestSchemaVersion())
+			}
+
+			q, err := App.store.LoadQuestion(1)
+			if err != nil || q.TeamID != tt.wantTeam || q.Message != "Ako sa darí?" {
+				t.Errorf("LoadQuestion() = %+v, %v, want team %q", q, err, tt.wantTeam)
+			}
+
+			closed, err := App.store.LoadInstance("C1", "1700000100.000000")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if closed.Status != StatusClosed || closed.Responses["U1"] != ResponseAnswered || closed.Responses["U2"] != ResponseMissing {
+				t.Errorf("older instance = %+v, want it closed with converted responses", closed)
+			}
+			if closed.Tally == nil || *closed.Tally != (Tally{Answered: 1, Missing: 1}) {
+				t.Errorf("tally of the older instance = %+v, want 1 answered and 1 missing", closed.Tally)
+			}
+
+			open, err := App.store.ListOpenInstances()
+			if err != nil || len(open) != 1 || open[0].Timestamp != "1700000200.000000" {
+				t.Errorf("ListOpenInstances() = %+v, %v, want the current instance", open, err)
+			}
+
+			instances, err := App.store.ListInstances()
+			want := []string{"1700000100.000000", "1700000200.000000"}
+			if got := instanceTimestamps(instances); err != nil || !slices.Equal(got, want) {
+				t.Errorf("ListInstances() = %v, %v, want %v without the deleted question", got, err, want)
+			}
+
+			teams, err := App.store.ListTeams()
+			if err != nil || len(teams) != 1 || teams[0].Token != "xoxb-baseline" {
+				t.Errorf("ListTeams() = %+v, %v, want the baseline team", teams, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/log"
+)
+
+type ParticipantMode string
+
+const (
+	ParticipantsUsers   ParticipantMode = ""        // the users picked in the web UI
+	ParticipantsChannel ParticipantMode = "channel" // all human members of the channel
+)
+
+// participants returns the users who are asked when a new instance is created,
+// together with the members of each user group of the question.
+func (q *Question) participants() ([]string, map[string][]string, error) {
+	if q.Participants == ParticipantsChannel {
+		users, err := q.channelParticipants()
+		return users, nil, err
+	}
+
+	users := slices.Clone(q.Users)
+	groupMembers := map[string][]string{}
+	var candidates []string
+	for _, group := range q.UserGroups {
+		members, err := ListUserGroupMembers(q.TeamID, group)
+		if err != nil {
+			// the round is still posted to everyone else
+			log.Error("Could not list members of user group, skipping it.", "question", q.ID, "group", group, "err", err)
+			continue
+		}
+
+		groupMembers[group] = members
+		for _, member := range members {
+			if !slices.Contains(users, member) && !slices.Contains(candidates, member) {
+				candidates = append(candidates, member)
+			}
+		}
+	}
+
+	humans, err := FilterHumanUsers(q.TeamID, candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+	users = append(users, humans...)
+
+	for group, members := range groupMembers {
+		members = slices.DeleteFunc(members, func(member string) bool {
+			return !slices.Contains(users, member)
+		})
+		if len(members) == 0 {
+			delete(groupMembers, group)
+			continue
+		}
+		groupMembers[group] = members
+	}
+
+	if len(groupMembers) == 0 {
+		groupMembers = nil
+	}
+	return users, groupMembers, nil
+}
+
+func (q *Question) channelParticipants() ([]string, error) {
+	members, err := ListChannelMembers(q.TeamID, q.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("could not list channel members: %w", err)
+	}
+
+	members = slices.DeleteFunc(members, func(member string) bool {
+		return slices.Contains(q.ExcludedUsers, member)
+	})
+	return FilterHumanUsers(q.TeamID, members)
+}
+
+// memberJoined adds a new channel member to the open instance of every question following the channel membership.
+func memberJoined(teamID, channel, user string) error {
+	return updateChannelParticipants(teamID, channel, user, func(q *Question, qi *QuestionInstance) (bool, error) {
+		if slices.Contains(q.ExcludedUsers, user) || q.Rotation.subset() {
+			return false, nil
+		}
+		if _, ok := qi.Responses[user]; ok {
+			return false, nil
+		}
+
+		human, err := IsHumanUser(teamID, user)
+		if err != nil || !human {
+			return false, err
+		}
+
+		qi.Responses[user] = ResponseMissing
+		return true, nil
+	})
+}
+
+// memberLeft removes a member who left the channel from the open instances, unless they already answered.
+func memberLeft(teamID, channel, user string) error {
+	return updateChannelParticipants(teamID, channel, user, func(q *Question, qi *QuestionInstance) (bool, error) {
+		if qi.Responses[user] != ResponseMissing {
+			return false, nil
+		}
+
+		delete(qi.Responses, user)
+		return true, nil
+	})
+}
+
+func updateChannelParticipants(teamID, channel, user string, update func(q *Question, qi *QuestionInstance) (bool, error)) error {
+	instances, err := App.store.ListOpenInstances()
+	if err != nil {
+		return err
+	}
+
+	for _, qi := range instances {
+		q := qi.Question
+		if q.TeamID != teamID || q.Channel != channel || q.Participants != ParticipantsChannel || q.IsDeleted() {
+			continue
+		}
+
+		err = updateInstance(q.Channel, qi.Timestamp, q, func(qi *QuestionInstance) error {
+			if !qi.IsOpen() {
+				return nil
+			}
+
+			changed, err := update(q, qi)
+			if err != nil || !changed {
+				return err
+			}
+
+			log.Info("Updating participants of an instance.", "question", q.ID, "instance", qi.Timestamp, "user", user)
+			err = qi.Save()
+			if err != nil {
+				return err
+			}
+
+			err = qi.PostMessage()
+			if err != nil {
+				log.Error("Could not update message.", "question", q.ID, "instance", qi.Timestamp, "err", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,12 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/charmbracelet/log"
 	"github.com/slack-go/slack"
-	bolt "go.etcd.io/bbolt"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -17,71 +14,78 @@ func PingMissingUsers() error {
 	// teamID, userID, []channelID
 	teamUserChannels := map[string]map[string][]string{}
 
-	err := App.db.View(func(tx *bolt.Tx) error {
-		return tx.Bucket([]byte("messages")).ForEach(func(k, v []byte) error {
-			var qi QuestionInstance
-			err := json.Unmarshal(v, &qi)
-			if err != nil {
-				return err
-			}
+	instances, err := App.store.ListOpenInstances()
+	if err != nil {
+		return err
+	}
 
-			err = qi.LoadQuestion()
-			if err != nil {
-				return err
-			}
+	// absences starting during a round are only applied now
+	err = excuseAbsentUsers(instances, time.Now())
+	if err != nil {
+		log.Error("Could not excuse absent users.", "err", err)
+	}
 
-			ts, err := strconv.ParseFloat(qi.Timestamp, 64)
-			if err != nil {
-				log.Error("Cannot parse timestamp for message.", "message", string(k), "err", err)
-				return nil // we ignore this error as it should not really happen, and it should not break the loop
-			}
+	for _, qi := range instances {
+		if !qi.IsOpen() || qi.Question.IsDeleted() {
+			continue
+		}
 
-			if qi.Question.CurrentInstance != qi.Timestamp {
-				log.Debug("Skipping - not current.", "instance", qi.Timestamp, "current", qi.Question.CurrentInstance)
-				return nil
-			}
+		posted, err := qi.PostedAt()
+		if err != nil {
+			log.Error("Cannot parse timestamp for message.", "question", qi.QuestionID, "message", qi.Timestamp, "err", err)
+			continue // we ignore this error as it should not really happen, and it should not break the loop
+		}
 
-			posted := time.Unix(int64(ts), 0)
-			if time.Now().Sub(posted) < 24*time.Hour {
-				log.Debug("Skipping - too soon.", "instance", qi.Timestamp)
-				return nil
-			}
+		if time.Now().Sub(posted) < 24*time.Hour {
+			log.Debug("Skipping - too soon.", "instance", qi.Timestamp)
+			continue
+		}
 
-			for user, replied := range qi.Responses {
-				if !replied {
-					if _, ok := teamUserChannels[qi.Question.TeamID]; !ok {
-						teamUserChannels[qi.Question.TeamID] = make(map[string][]string)
-					}
-					teamUserChannels[qi.Question.TeamID][user] = append(teamUserChannels[qi.Question.TeamID][user], qi.Question.Channel)
+		for user, status := range qi.Responses {
+			if status == ResponseMissing {
+				if _, ok := teamUserChannels[qi.Question.TeamID]; !ok {
+					teamUserChannels[qi.Question.TeamID] = make(map[string][]string)
 				}
+				teamUserChannels[qi.Question.TeamID][user] = append(teamUserChannels[qi.Question.TeamID][user], qi.Question.Channel)
 			}
-			return nil
-		})
-	})
-	if err != nil {
-		return err
+		}
 	}
 
 	for team, userChannels := range teamUserChannels {
-		client, ok := App.slack[team]
-		if !ok {
-			log.Error("Not pinging team as we do not have a connection there.", "team", team)
+		sendPings(team, userChannels)
+	}
+	return nil
+}
+
+// sendPings sends a direct message to every user with the channels where they did not answer yet.
+func sendPings(team string, userChannels map[string][]string) {
+	client, ok := App.slack[team]
+	if !ok {
+		log.Error("Not pinging team as we do not have a connection there.", "team", team)
+		return
+	}
+
+	for user, channels := range userChannels {
+		settings, err := LoadUserSettings(team, user)
+		if err != nil {
+			// better to ping someone who muted reminders than to miss someone who did not
+			log.Error("Could not load user settings.", "team", team, "user", user, "err", err)
+		}
+		if settings.MuteReminders {
+			log.Debug("Not pinging, reminders are muted.", "team", team, "user", user)
 			continue
 		}
 
-		for user, channels := range userChannels {
-			log.Info("Pinging.", "team", team, "user", user, "channels", channels)
-			msg := "Ahoj, zatiaľ si sa nevyjadril/-a do môjho update threadu v týchto kanáloch:\n%s\nNájdi si prosím minútku a doplň odpovede 😇"
-			var channelMentions []string
-			for _, channel := range channels {
-				channelMentions = append(channelMentions, fmt.Sprintf("<#%s>", channel))
-			}
+		log.Info("Pinging.", "team", team, "user", user, "channels", channels)
+		msg := "Ahoj, zatiaľ si sa nevyjadril/-a do môjho update threadu v týchto kanáloch:\n%s\nNájdi si prosím minútku a doplň odpovede 😇 Všetko, čo ti chýba, nájdeš aj na mojej domovskej obrazovke."
+		var channelMentions []string
+		for _, channel := range channels {
+			channelMentions = append(channelMentions, fmt.Sprintf("<#%s>", channel))
+		}
 
-			_, _, err := client.PostMessage(user, slack.MsgOptionText(fmt.Sprintf(msg, strings.Join(channelMentions, ", ")), false))
-			if err != nil {
-				log.Error("Could not send ping message.", "user", user, "err", err)
-			}
+		_, _, err = client.PostMessage(user, slack.MsgOptionText(fmt.Sprintf(msg, strings.Join(channelMentions, ", ")), false))
+		if err != nil {
+			log.Error("Could not send ping message.", "user", user, "err", err)
 		}
 	}
-	return nil
 }
@@ -1,87 +1,99 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"strconv"
+	"slices"
+	"time"
 
-	bolt "go.etcd.io/bbolt"
+	"github.com/charmbracelet/log"
 )
 
 type Question struct {
-	ID              uint64   // question unique identifier
-	TeamID          string   // slack team identifier
-	Channel         string   // slack channel identifier
-	Message         string   // question message text
-	Users           []string // involved users
-	Cron            string   // crontab expression of the question
-	CurrentInstance string   // timestamp of the latest instance
-	IsActive        bool     // whether this question is active
+	ID              uint64          // question unique identifier
+	TeamID          string          // slack team identifier
+	Channel         string          // slack channel identifier
+	Message         string          // question message text
+	Layout          string          // text/template of the message, empty means the default layout
+	Greetings       []string        // greetings picked from at random, empty means the default ones
+	Completions     []string        // messages shown when nobody is missing, picked at random, empty means the default ones
+	Rounds          int             // how many rounds were created
+	Prompts         []Prompt        // form fields answered through a modal, none means replies are free text
+	Users           []string        // involved users, unless the participants follow the channel
+	UserGroups      []string        // slack user groups whose members are involved, unless the participants follow the channel
+	Participants    ParticipantMode // who is asked
+	ExcludedUsers   []string        // users never asked when the participants follow the channel
+	Rotation        RotationMode    // whether only some of the participants are picked for each round
+	RotationSize    int             // how many participants are picked for each round, 0 means one
+	RotationQueue   []string        // participants in the order in which they are picked, kept across rounds
+	Schedule        ScheduleKind    // whether the question runs by its cron expression or at listed times
+	Cron            string          // crontab expression of the question
+	RunAt           []time.Time     // upcoming runs of a one-off question, ordered
+	PastRuns        []time.Time     // runs of a one-off question which already happened
+	Timezone        string          // IANA time zone of the cron expression, empty means the server time zone
+	HolidayPolicy   HolidayPolicy   // what happens with runs scheduled on a holiday of the team
+	ShiftedRun      time.Time       // when a run moved from a holiday happens, zero if there is none
+	SkipNextRun     bool            // the next scheduled run does not happen
+	SkippedRuns     []SkippedRun    // recent runs which did not happen on their day because of a holiday
+	Quorum          string          // answers needed to consider a round done, a count ("5") or a percentage ("60%"), empty means everyone
+	NotifyQuorum    bool            // send a direct message to the owner when a round reaches its quorum
+	Owner           string          // slack user who created the question, empty for questions created before owners were recorded
+	Deadline        string          // when rounds close, a duration after posting or a time of day, empty means never
+	AnnounceClose   bool            // post a reply with the missing users when a round closes at its deadline
+	CurrentInstance string          // timestamp of the latest instance
+	IsActive        bool            // whether this question is active
+	PollDays        *int            // overrides how many days new replies are checked, 0 means forever, nil means the global default
+	ArchiveDays     *int            // overrides after how many days instances are archived, 0 means never, nil means the global default
+	DeletedAt       time.Time       // when the question was moved to the trash, zero if it is not deleted
+	DeleteReason    DeleteReason    // why the question was moved to the trash
 }
 
-func (q *Question) Save() error {
-	return App.db.Update(func(tx *bolt.Tx) error {
-		questionsBucket := tx.Bucket([]byte("questions"))
-
-		if q.ID == 0 {
-			id, err := questionsBucket.NextSequence()
-			if err != nil {
-				return err
-			}
-			q.ID = id
-		}
+// Prompt is a single field of the answer form of a question.
+type Prompt struct {
+	Label    string
+	Required bool
+}
 
-		data, err := json.Marshal(q)
-		if err != nil {
-			return err
-		}
+type DeleteReason string
 
-		return questionsBucket.Put([]byte(strconv.FormatUint(q.ID, 10)), data)
-	})
+const (
+	DeletedByUser      DeleteReason = "user"    // deleted in the web UI
+	DeletedChannelGone DeleteReason = "channel" // the channel was archived or the bot was removed from it
+)
+
+func (q *Question) Save() error {
+	return App.store.SaveQuestion(q)
 }
 
-func (q *Question) Delete() error {
-	return App.db.Update(func(tx *bolt.Tx) error {
-		instanceBucket := tx.Bucket([]byte("messages"))
-		instances := [][]byte{}
-		instanceBucket.ForEach(func(k, v []byte) error {
-			var qi QuestionInstance
-			err := json.Unmarshal(v, &qi)
-			if err != nil {
-				return err
-			}
-
-			if qi.QuestionID == q.ID {
-				instances = append(instances, k)
-			}
-			return nil
-		})
-
-		for _, instance := range instances {
-			err := instanceBucket.Delete(instance)
-			if err != nil {
-				return err
-			}
-		}
+// Delete moves the question to the trash, where it is kept with all its instances
+// until the trash grace period passes.
+func (q *Question) Delete(reason DeleteReason) error {
+	if q.IsDeleted() {
+		return nil
+	}
 
-		questionsBucket := tx.Bucket([]byte("questions"))
-		return questionsBucket.Delete([]byte(strconv.FormatUint(q.ID, 10)))
-	})
+	q.DeletedAt = time.Now()
+	q.DeleteReason = reason
+	return q.Save()
 }
 
-func (q *Question) Instance() (QuestionInstance, error) {
-	var instance QuestionInstance
+// Restore takes the question out of the trash.
+func (q *Question) Restore() error {
+	q.DeletedAt = time.Time{}
+	q.DeleteReason = ""
+	return q.Save()
+}
 
-	err := App.db.View(func(tx *bolt.Tx) error {
-		data := tx.Bucket([]byte("messages")).Get([]byte(fmt.Sprintf("%s:%s", q.Channel, q.CurrentInstance)))
-		if data == nil {
-			return nil
-		}
+func (q *Question) IsDeleted() bool {
+	return !q.DeletedAt.IsZero()
+}
 
-		err := json.Unmarshal(data, &instance)
-		return err
-	})
+// Purge permanently removes the question together with all of its instances.
+func (q *Question) Purge() error {
+	return App.store.DeleteQuestion(q.ID)
+}
 
+func (q *Question) Instance() (QuestionInstance, error) {
+	instance, err := App.store.LoadInstance(q.Channel, q.CurrentInstance)
 	instance.Question = q
 	return instance, err
 }
@@ -90,14 +102,50 @@ func (q *Question) NewInstance() error {
 	qi := QuestionInstance{
 		Question:   q,
 		QuestionID: q.ID,
-		Responses:  make(map[string]bool),
+		Responses:  make(map[string]ResponseStatus),
+		Status:     StatusOpen,
+		Greeting:   q.pickGreeting(),
+		Completion: q.pickCompletion(),
+	}
+
+	round, err := q.nextRound()
+	if err != nil {
+		return fmt.Errorf("failed counting rounds: %w", err)
 	}
+	qi.Round = round
 
-	for _, user := range q.Users {
-		qi.Responses[user] = false
+	users, groupMembers, err := q.participants()
+	if err != nil {
+		return fmt.Errorf("failed resolving participants: %w", err)
+	}
+	qi.GroupMembers = groupMembers
+	absent, err := absentUsers(q.TeamID, time.Now().In(q.location()).Format(time.DateOnly))
+	if err != nil {
+		return fmt.Errorf("failed loading absences: %w", err)
+	}
+	if q.Rotation != RotationNone {
+		picked := q.rotate(users, absent)
+		if q.Rotation.subset() {
+			users = picked
+		} else if len(picked) != 0 {
+			qi.Facilitator = picked[0]
+		}
+	}
+	for _, user := range users {
+		qi.Responses[user] = ResponseMissing
+		if slices.Contains(absent, user) {
+			qi.Responses[user] = ResponseExcused
+		}
+	}
+
+	qi.Deadline, err = q.deadlineAt(time.Now())
+	if err != nil {
+		return err
 	}
 
-	err := qi.PostMessage()
+	previous := q.CurrentInstance
+
+	err = qi.PostMessage()
 	if err != nil {
 		return fmt.Errorf("failed posting message: %w", err)
 	}
@@ -108,23 +156,36 @@ func (q *Question) NewInstance() error {
 	}
 
 	q.CurrentInstance = qi.Timestamp
+	q.Rounds = round
 	err = q.Save()
 	if err != nil {
 		return fmt.Errorf("failed saving question: %w", err)
 	}
 
+	if previous != "" {
+		err = closeInstance(q, previous)
+		if err != nil {
+			log.Error("Could not close previous instance.", "question", q.ID, "instance", previous, "err", err)
+		}
+	}
+
 	return nil
 }
 
-func LoadQuestion(id uint64) (Question, error) {
-	var q Question
-	err := App.db.View(func(tx *bolt.Tx) error {
-		data := tx.Bucket([]byte("questions")).Get([]byte(strconv.FormatUint(id, 10)))
-		if data == nil {
-			return nil
-		}
+// CloseCurrentInstance manually closes the running round of the question.
+func (q *Question) CloseCurrentInstance() error {
+	if q.CurrentInstance == "" {
+		return nil
+	}
+	return closeInstance(q, q.CurrentInstance)
+}
 
-		return json.Unmarshal(data, &q)
+func closeInstance(q *Question, timestamp string) error {
+	return updateInstance(q.Channel, timestamp, q, func(qi *QuestionInstance) error {
+		return qi.Close(StatusClosed)
 	})
-	return q, err
+}
+
+func LoadQuestion(id uint64) (Question, error) {
+	return App.store.LoadQuestion(id)
 }
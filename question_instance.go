@@ -3,11 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/charmbracelet/log"
 	"github.com/slack-go/slack"
-	bolt "go.etcd.io/bbolt"
 )
 
 var Greetings = []string{
@@ -22,63 +26,284 @@ var Greetings = []string{
 	"Long time no see.",
 }
 
+type InstanceStatus string
+
+const (
+	StatusOpen    InstanceStatus = "open"    // the round is running
+	StatusClosed  InstanceStatus = "closed"  // superseded by a new round, closed manually or after its deadline
+	StatusExpired InstanceStatus = "expired" // too old to be checked for new replies
+)
+
+type ResponseStatus string
+
+const (
+	ResponseMissing  ResponseStatus = "missing"
+	ResponseAnswered ResponseStatus = "answered"
+	ResponseLate     ResponseStatus = "late"    // answered after the round was closed
+	ResponseExcused  ResponseStatus = "excused" // out of office, not expected to answer
+	ResponseNothing  ResponseStatus = "nothing" // answered that there is nothing to report
+	ResponseSkipped  ResponseStatus = "skipped" // chose to skip the round, not expected to answer
+)
+
+// UnmarshalJSON also accepts booleans, which were used before response statuses existed.
+func (s *ResponseStatus) UnmarshalJSON(data []byte) error {
+	var answered bool
+	if json.Unmarshal(data, &answered) == nil {
+		*s = ResponseMissing
+		if answered {
+			*s = ResponseAnswered
+		}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*string)(s))
+}
+
+// replaceable reports whether the status may be replaced by a written answer while the round is open.
+func (s ResponseStatus) replaceable() bool {
+	return s == ResponseExcused || s == ResponseNothing || s == ResponseSkipped
+}
+
+// Tally is the final result of a round, computed when it is closed.
+// Excused users and users who skipped the round do not count towards participation.
+type Tally struct {
+	Answered int // including users with nothing to report
+	Missing  int
+	Excused  int
+	Skipped  int
+	Quorum   int // answers needed for the quorum when the round was closed, 0 if the question had none
+}
+
+// Expected is how many users were expected to answer, excused users do not count.
+func (t *Tally) Expected() int {
+	return t.Answered + t.Missing
+}
+
 type QuestionInstance struct {
-	Question    *Question `json:"-"`
-	QuestionID  uint64
-	Timestamp   string
-	LastMessage string
-	Responses   map[string]bool
-	Greeting    string
+	Question        *Question `json:"-"`
+	QuestionID      uint64
+	Timestamp       string
+	LastMessage     string
+	Responses       map[string]ResponseStatus
+	GroupMembers    map[string][]string // members of each user group of the question when the instance was created
+	Facilitator     string              // user leading the round, if the question rotates a facilitator
+	Replies         []Reply
+	Answers         []FormAnswer
+	Round           int // number of the round, starting at 1, 0 for rounds created before they were numbered
+	Greeting        string
+	Completion      string // message shown when nobody is missing, empty means the default one
+	Status          InstanceStatus
+	QuorumReachedAt time.Time // when enough users answered to reach the quorum, zero if they did not
+	Deadline        time.Time // when the round closes automatically, zero if it does not
+	ClosedAt        time.Time
+	Tally           *Tally
+}
+
+// Reply is a single message posted into the thread of a question instance.
+type Reply struct {
+	User      string      // slack user identifier
+	Timestamp string      // slack timestamp of the reply
+	Text      string      // current text of the reply
+	Files     []string    // identifiers of attached files
+	Edits     []ReplyEdit // previous versions of the reply, oldest first
+}
+
+type ReplyEdit struct {
+	Timestamp string // slack timestamp of the edit
+	Text      string // text of the reply before the edit
+}
+
+// FormAnswer is a reply submitted through the answer form of a question with prompts.
+type FormAnswer struct {
+	User      string        // slack user identifier
+	Timestamp string        // slack timestamp of the reply posted into the thread
+	Fields    []AnswerField // answers in the order of the prompts
+}
+
+type AnswerField struct {
+	Prompt string // label of the prompt at the time of answering, empty for questions without prompts
+	Text   string // empty if an optional prompt was skipped
+}
+
+func newReply(msg slack.Msg) Reply {
+	reply := Reply{
+		User:      msg.User,
+		Timestamp: msg.Timestamp,
+		Text:      msg.Text,
+	}
+
+	for _, file := range msg.Files {
+		reply.Files = append(reply.Files, file.ID)
+	}
+	return reply
+}
+
+func (qi *QuestionInstance) findReply(timestamp string) *Reply {
+	for i := range qi.Replies {
+		if qi.Replies[i].Timestamp == timestamp {
+			return &qi.Replies[i]
+		}
+	}
+	return nil
 }
 
 func (qi *QuestionInstance) Message() string {
 	if qi.Greeting == "" {
-		qi.Greeting = Greetings[rand.Intn(len(Greetings))]
+		qi.Greeting = qi.Question.pickGreeting()
+	}
+
+	message, err := qi.renderMessage()
+	if err != nil {
+		log.Error("Could not render message layout.", "question", qi.QuestionID, "err", err)
 	}
+	return message
+}
 
-	var message []string
-	message = append(message, fmt.Sprintf("👋 %s", qi.Greeting))
-	message = append(message, "")
-	message = append(message, fmt.Sprintf("> %s", strings.ReplaceAll(qi.Question.Message, "\n", "\n> ")))
-	message = append(message, "")
+// IsOpen reports whether the round is still running. Instances created before
+// statuses existed have no status and are treated as open.
+func (qi *QuestionInstance) IsOpen() bool {
+	return qi.Status == StatusOpen || qi.Status == ""
+}
 
-	var usersOk, usersMissing []string
-	for user, ok := range qi.Responses {
-		mention := fmt.Sprintf("<@%s>", user)
-		if ok {
-			usersOk = append(usersOk, mention)
-		} else {
-			usersMissing = append(usersMissing, mention)
+func (qi *QuestionInstance) tally() Tally {
+	var tally Tally
+	for _, status := range qi.Responses {
+		switch status {
+		case ResponseAnswered, ResponseNothing:
+			tally.Answered++
+		case ResponseExcused:
+			tally.Excused++
+		case ResponseSkipped:
+			tally.Skipped++
+		default:
+			tally.Missing++
 		}
 	}
+	return tally
+}
 
-	if len(usersMissing) != 0 {
-		message = append(message, "_Napíšte za seba update do threadu._")
-		message = append(message, fmt.Sprintf("❌: %s", strings.Join(usersMissing, ", ")))
-		message = append(message, fmt.Sprintf("✅: %s", strings.Join(usersOk, ", ")))
-	} else {
-		message = append(message, "🎉 Všetci už napísali svoj update, weeee!")
+// Close ends the round with the given status, records the final tally and re-renders the message.
+func (qi *QuestionInstance) Close(status InstanceStatus) error {
+	if !qi.IsOpen() {
+		return nil
 	}
 
-	return strings.Join(message, "\n")
+	qi.finish(status)
+	err := qi.Save()
+	if err != nil {
+		return err
+	}
+
+	return qi.PostMessage()
 }
 
-func (qi *QuestionInstance) PostMessage() error {
+// finish marks the instance as closed with the given status and records its final tally.
+func (qi *QuestionInstance) finish(status InstanceStatus) {
+	tally := qi.tally()
+	tally.Quorum = qi.Question.quorum(tally.Expected())
+	qi.Status = status
+	qi.ClosedAt = time.Now()
+	qi.Tally = &tally
+}
+
+// messageOptions returns the message as Block Kit blocks with the progress of the round and,
+// while it is open, buttons for answering without writing into the thread. The plain text is
+// kept as a fallback for notifications.
+func (qi *QuestionInstance) messageOptions() []slack.MsgOption {
 	message := qi.Message()
+	tally := qi.tally()
+
+	var blocks []slack.Block
+	for _, text := range splitText(message, sectionTextLimit) {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+	}
+	if tally.Expected() != 0 {
+		progress := fmt.Sprintf("%s %d / %d", progressBar(tally.Answered, tally.Expected()), tally.Answered, tally.Expected())
+		blocks = append(blocks, slack.NewContextBlock("progress", slack.NewTextBlockObject(slack.MarkdownType, progress, false, false)))
+	}
+
+	if qi.IsOpen() {
+		var buttons []slack.BlockElement
+		if len(qi.Question.Prompts) != 0 {
+			buttons = append(buttons, slack.NewButtonBlockElement(answerActionID, "", slack.NewTextBlockObject(slack.PlainTextType, "Odpovedať", false, false)).
+				WithStyle(slack.StylePrimary))
+		}
+		buttons = append(buttons,
+			slack.NewButtonBlockElement(nothingActionID, "", slack.NewTextBlockObject(slack.PlainTextType, "🤷 Nič nové", true, false)),
+			slack.NewButtonBlockElement(skipActionID, "", slack.NewTextBlockObject(slack.PlainTextType, "⏭️ Vynechám toto kolo", true, false)),
+			slack.NewButtonBlockElement(oooActionID, "", slack.NewTextBlockObject(slack.PlainTextType, "🌴 Dnes som mimo", true, false)),
+		)
+		blocks = append(blocks, slack.NewActionBlock("actions", buttons...))
+	}
+
+	return []slack.MsgOption{slack.MsgOptionText(message, false), slack.MsgOptionBlocks(blocks...)}
+}
+
+// sectionTextLimit is the longest text Slack accepts in a section block.
+const sectionTextLimit = 3000
+
+// splitText splits the text into parts of at most limit bytes, preferably between lines, so that
+// messages listing many users, such as rounds of large channels, fit into section blocks.
+// Lines which are too long on their own are split between words.
+func splitText(text string, limit int) []string {
+	var parts []string
+	current := ""
+	for _, line := range strings.Split(text, "\n") {
+		for len(line) > limit {
+			cut := strings.LastIndex(line[:limit], " ")
+			if cut <= 0 {
+				cut = limit
+				for !utf8.RuneStart(line[cut]) {
+					cut--
+				}
+			}
+
+			if current != "" {
+				parts = append(parts, current)
+				current = ""
+			}
+			parts = append(parts, line[:cut])
+			line = strings.TrimLeft(line[cut:], " ")
+		}
+
+		switch {
+		case current == "":
+			current = line
+		case len(current)+1+len(line) <= limit:
+			current += "\n" + line
+		default:
+			parts = append(parts, current)
+			current = line
+		}
+	}
+	if current != "" || len(parts) == 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// progressBar draws how many of the expected users answered.
+func progressBar(done, total int) string {
+	const width = 10
+	filled := done * width / total
+	return strings.Repeat("▰", filled) + strings.Repeat("▱", width-filled)
+}
+
+func (qi *QuestionInstance) PostMessage() error {
 	client, ok := App.slack[qi.Question.TeamID]
 	if !ok {
 		return fmt.Errorf("not connected to team %s", qi.Question.TeamID)
 	}
 
 	if qi.Timestamp == "" {
-		_, ts, err := client.PostMessage(qi.Question.Channel, slack.MsgOptionText(message, false))
+		_, ts, err := client.PostMessage(qi.Question.Channel, qi.messageOptions()...)
 		if err != nil {
 			return err
 		}
 
 		qi.Timestamp = ts
 	} else {
-		_, _, _, err := client.UpdateMessage(qi.Question.Channel, qi.Timestamp, slack.MsgOptionText(message, false))
+		_, _, _, err := client.UpdateMessage(qi.Question.Channel, qi.Timestamp, qi.messageOptions()...)
 		if err != nil {
 			return err
 		}
@@ -87,15 +312,38 @@ func (qi *QuestionInstance) PostMessage() error {
 	return nil
 }
 
-func (qi *QuestionInstance) HandleMessage(user string, timestamp string) error {
-	alreadyReplied, expected := qi.Responses[user]
-	if !expected || alreadyReplied {
-		qi.LastMessage = timestamp
+func (qi *QuestionInstance) HandleMessage(msg slack.Msg) error {
+	qi.LastMessage = msg.Timestamp
+	if msg.Timestamp == qi.Timestamp || msg.BotID != "" {
+		return qi.Save()
+	}
+
+	if reply := qi.findReply(msg.Timestamp); reply != nil {
+		return qi.HandleEdit(msg)
+	}
+	qi.Replies = append(qi.Replies, newReply(msg))
+	return qi.recordResponse(msg.User)
+}
+
+// recordResponse marks the user as having answered, late if the round is already closed.
+func (qi *QuestionInstance) recordResponse(user string) error {
+	// users who did not answer by writing may still do so while the round is open
+	status, expected := qi.Responses[user]
+	if !expected || (status != ResponseMissing && (!status.replaceable() || !qi.IsOpen())) {
 		return qi.Save()
 	}
 
-	qi.Responses[user] = true
-	qi.LastMessage = timestamp
+	if !qi.IsOpen() {
+		qi.Responses[user] = ResponseLate
+		err := qi.Save()
+		if err != nil {
+			return err
+		}
+		return qi.PostMessage()
+	}
+
+	qi.Responses[user] = ResponseAnswered
+	quorumReached := qi.checkQuorum()
 	err := qi.Save()
 	if err != nil {
 		return err
@@ -106,6 +354,13 @@ func (qi *QuestionInstance) HandleMessage(user string, timestamp string) error {
 		return err
 	}
 
+	if quorumReached {
+		err = qi.notifyQuorum()
+		if err != nil {
+			log.Error("Could not notify the owner about the quorum.", "question", qi.QuestionID, "instance", qi.Timestamp, "err", err)
+		}
+	}
+
 	client, ok := App.slack[qi.Question.TeamID]
 	if !ok {
 		return fmt.Errorf("not connected to team %s", qi.Question.TeamID)
@@ -114,6 +369,105 @@ func (qi *QuestionInstance) HandleMessage(user string, timestamp string) error {
 	return err
 }
 
+// RespondWithButton records a response given by one of the buttons on the message, reporting whether
+// it was recorded. Only users who did not write an answer yet can use the buttons while the round is open.
+// Users who are out of office also get an absence for today, so that other rounds excuse them as well.
+func (qi *QuestionInstance) RespondWithButton(user string, status ResponseStatus) (bool, error) {
+	current, expected := qi.Responses[user]
+	if !qi.IsOpen() || !expected || (current != ResponseMissing && !current.replaceable()) {
+		return false, nil
+	}
+
+	qi.Responses[user] = status
+	quorumReached := qi.checkQuorum()
+	err := qi.Save()
+	if err != nil {
+		return false, err
+	}
+
+	err = qi.PostMessage()
+	if err != nil {
+		return true, err
+	}
+
+	if quorumReached {
+		err = qi.notifyQuorum()
+		if err != nil {
+			log.Error("Could not notify the owner about the quorum.", "question", qi.QuestionID, "instance", qi.Timestamp, "err", err)
+		}
+	}
+
+	if status == ResponseExcused {
+		today := time.Now().In(qi.Question.location()).Format(time.DateOnly)
+		absent, err := absentUsers(qi.Question.TeamID, today)
+		if err != nil || slices.Contains(absent, user) {
+			return true, err
+		}
+
+		absence, err := NewAbsence(qi.Question.TeamID, user, today, today, user)
+		if err != nil {
+			return true, err
+		}
+		return true, absence.Save()
+	}
+	return true, nil
+}
+
+// HandleFormAnswer posts the answer into the thread on behalf of the user and records it.
+func (qi *QuestionInstance) HandleFormAnswer(answer FormAnswer) error {
+	client, ok := App.slack[qi.Question.TeamID]
+	if !ok {
+		return fmt.Errorf("not connected to team %s", qi.Question.TeamID)
+	}
+
+	_, ts, err := client.PostMessage(qi.Question.Channel, slack.MsgOptionText(answer.Message(), false), slack.MsgOptionTS(qi.Timestamp))
+	if err != nil {
+		return err
+	}
+
+	answer.Timestamp = ts
+	qi.Answers = append(qi.Answers, answer)
+	return qi.recordResponse(answer.User)
+}
+
+// Message formats the answer as a thread reply.
+func (a FormAnswer) Message() string {
+	message := []string{fmt.Sprintf("📝 Update od <@%s>:", a.User)}
+	for _, field := range a.Fields {
+		switch {
+		case field.Text == "":
+			continue
+		case field.Prompt == "":
+			message = append(message, "", field.Text)
+		default:
+			message = append(message, "", fmt.Sprintf("*%s*", field.Prompt), field.Text)
+		}
+	}
+	return strings.Join(message, "\n")
+}
+
+// HandleEdit records a new version of an already stored reply.
+func (qi *QuestionInstance) HandleEdit(msg slack.Msg) error {
+	reply := qi.findReply(msg.Timestamp)
+	if reply == nil {
+		return nil
+	}
+
+	updated := newReply(msg)
+	if reply.Text == updated.Text && slices.Equal(reply.Files, updated.Files) {
+		return nil
+	}
+
+	edit := ReplyEdit{Text: reply.Text}
+	if msg.Edited != nil {
+		edit.Timestamp = msg.Edited.Timestamp
+	}
+	reply.Edits = append(reply.Edits, edit)
+	reply.Text = updated.Text
+	reply.Files = updated.Files
+	return qi.Save()
+}
+
 func (qi *QuestionInstance) CheckNewMessages() error {
 	cursor := ""
 	hasMore := true
@@ -138,7 +492,7 @@ func (qi *QuestionInstance) CheckNewMessages() error {
 		}
 
 		for _, message := range messages {
-			err := qi.HandleMessage(message.User, message.Timestamp)
+			err := qi.HandleMessage(message.Msg)
 			if err != nil {
 				return err
 			}
@@ -148,27 +502,30 @@ func (qi *QuestionInstance) CheckNewMessages() error {
 	return nil
 }
 
-func (qi *QuestionInstance) dbKey() []byte {
-	return []byte(fmt.Sprintf("%s:%s", qi.Question.Channel, qi.Timestamp))
+// PostedAt returns the time when the instance was posted, derived from its slack timestamp.
+func (qi *QuestionInstance) PostedAt() (time.Time, error) {
+	ts, err := strconv.ParseFloat(qi.Timestamp, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(ts), 0), nil
 }
 
-func (qi *QuestionInstance) Save() error {
-	return App.db.Update(func(tx *bolt.Tx) error {
-		messages := tx.Bucket([]byte("messages"))
-		data, err := json.Marshal(qi)
-		if err != nil {
-			return err
-		}
+// slackTimestamp formats the time the way slack formats message timestamps, so that it can be
+// compared with them as a string. Zero time is formatted as an empty string, which precedes all timestamps.
+func slackTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d.000000", t.Unix())
+}
 
-		return messages.Put(qi.dbKey(), data)
-	})
+func (qi *QuestionInstance) Save() error {
+	return App.store.SaveInstance(qi)
 }
 
 func (qi *QuestionInstance) Delete() error {
-	return App.db.Update(func(tx *bolt.Tx) error {
-		messages := tx.Bucket([]byte("messages"))
-		return messages.Delete(qi.dbKey())
-	})
+	return App.store.DeleteInstance(qi.Question.Channel, qi.Timestamp)
 }
 
 func (qi *QuestionInstance) LoadQuestion() error {
@@ -180,18 +537,70 @@ func (qi *QuestionInstance) LoadQuestion() error {
 	return nil
 }
 
-func LoadQuestionInstance(channel string, timestamp string) (QuestionInstance, error) {
-	key := []byte(fmt.Sprintf("%s:%s", channel, timestamp))
-	var qi QuestionInstance
+// instanceLocks holds a mutex per instance. Slack events, the scheduler and the web UI run concurrently,
+// so everything which loads, modifies and saves an instance locks it first, otherwise the last save would
+// drop the other changes. For example, posting a form answer triggers a message event for the bot's own reply.
+// Locks are removed once nobody holds or waits for them, so the map only holds instances in use.
+var instanceLocks = struct {
+	sync.Mutex
+	locks map[string]*instanceLock
+}{locks: map[string]*instanceLock{}}
+
+type instanceLock struct {
+	sync.Mutex
+	users int // goroutines holding or waiting for the lock
+}
+
+// lockInstance locks the instance for a load-modify-save cycle and returns the function unlocking it.
+func lockInstance(channel string, timestamp string) func() {
+	key := channel + "/" + timestamp
+
+	instanceLocks.Lock()
+	lock, ok := instanceLocks.locks[key]
+	if !ok {
+		lock = &instanceLock{}
+		instanceLocks.locks[key] = lock
+	}
+	lock.users++
+	instanceLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		instanceLocks.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(instanceLocks.locks, key)
+		}
+		instanceLocks.Unlock()
+	}
+}
 
-	err := App.db.View(func(tx *bolt.Tx) error {
-		data := tx.Bucket([]byte("messages")).Get(key)
-		if data == nil {
-			return nil
+// updateInstance locks the instance, reloads it and passes the fresh copy to update, which saves its changes.
+// The question of the instance is loaded unless it is given. Instances which no longer exist are skipped.
+func updateInstance(channel string, timestamp string, question *Question, update func(qi *QuestionInstance) error) error {
+	unlock := lockInstance(channel, timestamp)
+	defer unlock()
+
+	qi, err := App.store.LoadInstance(channel, timestamp)
+	if err != nil || qi.QuestionID == 0 {
+		return err
+	}
+
+	if question != nil {
+		qi.Question = question
+	} else {
+		err = qi.LoadQuestion()
+		if err != nil {
+			return err
 		}
+	}
+	return update(&qi)
+}
 
-		return json.Unmarshal(data, &qi)
-	})
+func LoadQuestionInstance(channel string, timestamp string) (QuestionInstance, error) {
+	qi, err := App.store.LoadInstance(channel, timestamp)
 	if err != nil {
 		return qi, err
 	}
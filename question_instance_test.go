@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplitText(t *testing.T) {
+	mentions := strings.Repeat("<@U0123456789>, ", 250)
+
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short", "Ahojte!\nČo nové?", 100, []string{"Ahojte!\nČo nové?"}},
+		{"empty", "", 100, []string{""}},
+		{"between lines", "aaaa\nbbbb\ncccc", 9, []string{"aaaa\nbbbb", "cccc"}},
+		{"long line between words", "aaa bbb ccc", 8, []string{"aaa bbb", "ccc"}},
+		{"long word", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"multibyte", "ččččč", 3, []string{"č", "č", "č", "č", "č"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitText(tt.text, tt.limit)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitText(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("mentions", func(t *testing.T) {
+		text := "Ahojte!\n\n❌: " + mentions + "\n✅: <@U1>"
+		parts := splitText(text, sectionTextLimit)
+		if len(parts) < 2 {
+			t.Fatalf("expected the text to be split, got %d part", len(parts))
+		}
+		for _, part := range parts {
+			if len(part) > sectionTextLimit {
+				t.Errorf("part has %d bytes, more than %d", len(part), sectionTextLimit)
+			}
+		}
+		if joined := strings.Join(parts, " "); strings.Count(joined, "<@U0123456789>") != 250 {
+			t.Errorf("mentions were lost or split")
+		}
+	})
+}
+
+func TestLockInstance(t *testing.T) {
+	counter := 0
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockInstance("C1", "1700000000.000100")
+			defer unlock()
+
+			// without the lock the read and the write of concurrent goroutines would interleave
+			value := counter
+			time.Sleep(time.Microsecond)
+			counter = value + 1
+		}()
+	}
+	wg.Wait()
+
+	if counter != 50 {
+		t.Errorf("counter = %d, want 50", counter)
+	}
+	instanceLocks.Lock()
+	defer instanceLocks.Unlock()
+	if len(instanceLocks.locks) != 0 {
+		t.Errorf("%d locks left after unlocking, want none", len(instanceLocks.locks))
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strconv"
+)
+
+// QuestionTemplate holds the settings of a question which new questions can start from.
+type QuestionTemplate struct {
+	ID       uint64   // template identifier, unique within the team, 0 for built-in templates
+	TeamID   string   // slack team identifier, empty for built-in templates
+	Key      string   // identifier of a built-in template
+	Name     string   // shown when picking a template
+	Question Question // settings copied into new questions
+}
+
+// BuiltinTemplates are offered in every team next to the templates defined by the team.
+var BuiltinTemplates = []QuestionTemplate{
+	{
+		Key:  "standup",
+		Name: "Denný standup",
+		Question: Question{
+			Message: "Čo sa udialo od včera a čo ťa čaká dnes?",
+			Prompts: []Prompt{
+				{Label: "Čo som robil/-a včera?", Required: true},
+				{Label: "Čo budem robiť dnes?", Required: true},
+				{Label: "Blokuje ma niečo?"},
+			},
+			Cron:     "0 9 * * 1-5",
+			Deadline: "12:00",
+			IsActive: true,
+		},
+	},
+	{
+		Key:  "weekly",
+		Name: "Týždenný update",
+		Question: Question{
+			Message: "Ako sa ti darilo tento týždeň?",
+			Prompts: []Prompt{
+				{Label: "Čo sa mi podarilo?", Required: true},
+				{Label: "Na čom budem pracovať budúci týždeň?", Required: true},
+				{Label: "S čím by som potreboval/-a pomôcť?"},
+			},
+			Cron:          "0 14 * * 5",
+			Deadline:      "17:00",
+			AnnounceClose: true,
+			IsActive:      true,
+		},
+	},
+	{
+		Key:  "retro",
+		Name: "Retrospektíva",
+		Question: Question{
+			Message: "Je čas na retrospektívu. Ako sa nám darilo?",
+			Prompts: []Prompt{
+				{Label: "Čo fungovalo dobre?", Required: true},
+				{Label: "Čo nefungovalo?", Required: true},
+				{Label: "Čo skúsime nabudúce inak?"},
+			},
+			Cron:     "0 13 * * 5",
+			Deadline: "48h",
+			IsActive: true,
+		},
+	},
+	{
+		Key:  "mood",
+		Name: "Ako sa máte?",
+		Question: Question{
+			Message:  "Ako sa dnes máš? Ohodnoť svoju náladu od 1 do 5 a ak chceš, pridaj pár slov.",
+			Cron:     "0 15 * * 5",
+			IsActive: true,
+		},
+	},
+}
+
+// NewQuestionTemplate creates a template of the team from the settings of an existing question.
+// Settings specific to the channel or the running rounds are left out.
+func NewQuestionTemplate(name string, q Question) QuestionTemplate {
+	return QuestionTemplate{
+		TeamID: q.TeamID,
+		Name:   name,
+		Question: Question{
+			Message:       q.Message,
+			Layout:        q.Layout,
+			Greetings:     q.Greetings,
+			Completions:   q.Completions,
+			Prompts:       q.Prompts,
+			UserGroups:    q.UserGroups,
+			Participants:  q.Participants,
+			Rotation:      q.Rotation,
+			RotationSize:  q.RotationSize,
+			Schedule:      q.Schedule,
+			Cron:          q.Cron,
+			Timezone:      q.Timezone,
+			HolidayPolicy: q.HolidayPolicy,
+			Quorum:        q.Quorum,
+			NotifyQuorum:  q.NotifyQuorum,
+			Deadline:      q.Deadline,
+			AnnounceClose: q.AnnounceClose,
+			IsActive:      q.IsActive,
+			PollDays:      q.PollDays,
+			ArchiveDays:   q.ArchiveDays,
+		},
+	}
+}
+
+// Ref identifies the template in the web UI, it is the key of built-in templates and the ID of the others.
+func (t QuestionTemplate) Ref() string {
+	if t.Key != "" {
+		return t.Key
+	}
+	return strconv.FormatUint(t.ID, 10)
+}
+
+func (t *QuestionTemplate) Save() error {
+	return App.store.SaveTemplate(t)
+}
+
+// ListQuestionTemplates returns the built-in templates followed by the templates of the team.
+func ListQuestionTemplates(teamID string) ([]QuestionTemplate, error) {
+	templates, err := App.store.ListTemplates(teamID)
+	if err != nil {
+		return nil, err
+	}
+	return append(BuiltinTemplates[:len(BuiltinTemplates):len(BuiltinTemplates)], templates...), nil
+}
+
+// FindQuestionTemplate returns the built-in or team template with the given reference.
+func FindQuestionTemplate(teamID string, ref string) (QuestionTemplate, bool, error) {
+	templates, err := ListQuestionTemplates(teamID)
+	if err != nil {
+		return QuestionTemplate{}, false, err
+	}
+
+	for _, template := range templates {
+		if template.Ref() == ref {
+			return template, true, nil
+		}
+	}
+	return QuestionTemplate{}, false, nil
+}
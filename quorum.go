@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// parseQuorum parses a quorum given as a count ("5") or a percentage ("60%").
+func parseQuorum(quorum string) (value int, percent bool, err error) {
+	number, percent := strings.CutSuffix(strings.TrimSpace(quorum), "%")
+	value, err = strconv.Atoi(strings.TrimSpace(number))
+	if err != nil || value < 1 || (percent && value > 100) {
+		return 0, false, fmt.Errorf("invalid quorum %q", quorum)
+	}
+	return value, percent, nil
+}
+
+// quorum returns how many answers are needed for the quorum when the given number of users
+// is expected to answer, 0 if the question has no quorum.
+func (q *Question) quorum(expected int) int {
+	if q.Quorum == "" || expected == 0 {
+		return 0
+	}
+
+	value, percent, err := parseQuorum(q.Quorum)
+	if err != nil {
+		return 0
+	}
+	if percent {
+		// rounded up, 50 % of 3 users are 2 users
+		value = (expected*value + 99) / 100
+	}
+	return min(value, expected)
+}
+
+// checkQuorum records when the round reached its quorum and reports whether it just happened.
+func (qi *QuestionInstance) checkQuorum() bool {
+	if !qi.QuorumReachedAt.IsZero() {
+		return false
+	}
+
+	tally := qi.tally()
+	needed := qi.Question.quorum(tally.Expected())
+	if needed == 0 || tally.Answered < needed {
+		return false
+	}
+
+	qi.QuorumReachedAt = time.Now()
+	return true
+}
+
+// notifyQuorum sends a direct message to the owner of the question that the round reached its quorum.
+func (qi *QuestionInstance) notifyQuorum() error {
+	if !qi.Question.NotifyQuorum || qi.Question.Owner == "" {
+		return nil
+	}
+
+	settings, err := LoadUserSettings(qi.Question.TeamID, qi.Question.Owner)
+	if err != nil || settings.MuteQuorum {
+		return err
+	}
+
+	client, ok := App.slack[qi.Question.TeamID]
+	if !ok {
+		return fmt.Errorf("not connected to team %s", qi.Question.TeamID)
+	}
+
+	permalink, err := client.GetPermalink(&slack.PermalinkParameters{Channel: qi.Question.Channel, Ts: qi.Timestamp})
+	if err != nil {
+		return err
+	}
+
+	tally := qi.tally()
+	text := fmt.Sprintf("✨ Buzerácia v <#%s> dosiahla kvórum, odpovedalo %d z %d. %s", qi.Question.Channel, tally.Answered, tally.Expected(), permalink)
+	_, _, err = client.PostMessage(qi.Question.Owner, slack.MsgOptionText(text, false))
+	return err
+}
+
+type RoundOutcome string
+
+const (
+	OutcomeComplete   RoundOutcome = "complete"   // everyone expected answered in time
+	OutcomeQuorum     RoundOutcome = "quorum"     // enough users answered in time, but not everyone
+	OutcomeIncomplete RoundOutcome = "incomplete" // the quorum was not reached
+	OutcomeEmpty      RoundOutcome = "empty"      // nobody was expected to answer, e.g. everyone was excused
+)
+
+// Outcome classifies a closed round by its final tally.
+func (t *Tally) Outcome() RoundOutcome {
+	switch {
+	case t.Expected() == 0:
+		return OutcomeEmpty
+	case t.Missing == 0:
+		return OutcomeComplete
+	case t.Quorum != 0 && t.Answered >= t.Quorum:
+		return OutcomeQuorum
+	default:
+		return OutcomeIncomplete
+	}
+}
+
+// RoundStats counts the closed rounds of a question by their outcome.
+type RoundStats struct {
+	Complete   int
+	Quorum     int
+	Incomplete int
+	Empty      int
+}
+
+func (s RoundStats) Total() int {
+	return s.Complete + s.Quorum + s.Incomplete + s.Empty
+}
+
+// QuestionRoundStats computes the statistics of the closed rounds of the question which are not archived.
+func QuestionRoundStats(questionID uint64) (RoundStats, error) {
+	var stats RoundStats
+
+	instances, err := App.store.ListQuestionInstances(questionID, time.Time{})
+	if err != nil {
+		return stats, err
+	}
+
+	for _, qi := range instances {
+		if qi.IsOpen() || qi.Tally == nil {
+			continue
+		}
+
+		switch qi.Tally.Outcome() {
+		case OutcomeComplete:
+			stats.Complete++
+		case OutcomeQuorum:
+			stats.Quorum++
+		case OutcomeEmpty:
+			stats.Empty++
+		default:
+			stats.Incomplete++
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import "testing"
+
+func TestParseQuorum(t *testing.T) {
+	tests := []struct {
+		quorum      string
+		wantValue   int
+		wantPercent bool
+		wantErr     bool
+	}{
+		{"5", 5, false, false},
+		{" 3 ", 3, false, false},
+		{"60%", 60, true, false},
+		{"100 %", 100, true, false},
+		{"1%", 1, true, false},
+		{"0", 0, false, true},
+		{"0%", 0, false, true},
+		{"101%", 0, false, true},
+		{"-2", 0, false, true},
+		{"half", 0, false, true},
+		{"%", 0, false, true},
+		{"", 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.quorum, func(t *testing.T) {
+			value, percent, err := parseQuorum(tt.quorum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQuorum(%q) error = %v, want error %v", tt.quorum, err, tt.wantErr)
+			}
+			if value != tt.wantValue || percent != tt.wantPercent {
+				t.Errorf("parseQuorum(%q) = %d, %v, want %d, %v", tt.quorum, value, percent, tt.wantValue, tt.wantPercent)
+			}
+		})
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	tests := []struct {
+		name     string
+		quorum   string
+		expected int
+		want     int
+	}{
+		{"no quorum", "", 10, 0},
+		{"nobody expected", "3", 0, 0},
+		{"count", "3", 10, 3},
+		{"count above expected", "5", 3, 3},
+		{"percentage exact", "50%", 4, 2},
+		{"percentage rounded up", "50%", 3, 2},
+		{"small percentage rounded up", "1%", 3, 1},
+		{"percentage just above a whole user", "34%", 3, 2},
+		{"percentage just below a whole user", "33%", 3, 1},
+		{"everyone", "100%", 7, 7},
+		{"invalid", "lots", 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Question{Quorum: tt.quorum}
+			got := q.quorum(tt.expected)
+			if got != tt.want {
+				t.Errorf("quorum(%d) with %q = %d, want %d", tt.expected, tt.quorum, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		name  string
+		tally Tally
+		want  RoundOutcome
+	}{
+		{"everyone answered", Tally{Answered: 4}, OutcomeComplete},
+		{"everyone answered or was excused", Tally{Answered: 3, Excused: 2}, OutcomeComplete},
+		{"quorum reached", Tally{Answered: 3, Missing: 1, Quorum: 3}, OutcomeQuorum},
+		{"quorum not reached", Tally{Answered: 2, Missing: 2, Quorum: 3}, OutcomeIncomplete},
+		{"someone missing without quorum", Tally{Answered: 3, Missing: 1}, OutcomeIncomplete},
+		{"nobody expected", Tally{Excused: 2, Skipped: 1}, OutcomeEmpty},
+		{"nobody at all", Tally{}, OutcomeEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.tally.Outcome()
+			if got != tt.want {
+				t.Errorf("Outcome() of %+v = %q, want %q", tt.tally, got, tt.want)
+			}
+		})
+	}
+}
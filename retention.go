@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const retentionCron = "40 3 * * *"
+
+// pollDays returns for how many days new replies to instances of the question are checked, 0 means forever.
+func (q *Question) pollDays() int {
+	if q.PollDays != nil {
+		return *q.PollDays
+	}
+	return App.config.PollDays
+}
+
+// archiveDays returns after how many days instances of the question are archived, 0 means never.
+func (q *Question) archiveDays() int {
+	if q.ArchiveDays != nil {
+		return *q.ArchiveDays
+	}
+	return App.config.ArchiveDays
+}
+
+// inheritZeroRetention turns overrides of 0 days back into the global defaults, which is what they
+// meant before a question could override the defaults to forever. Used for old exports.
+func (q *Question) inheritZeroRetention() {
+	if q.PollDays != nil && *q.PollDays == 0 {
+		q.PollDays = nil
+	}
+	if q.ArchiveDays != nil && *q.ArchiveDays == 0 {
+		q.ArchiveDays = nil
+	}
+}
+
+// ApplyRetention expires open instances which are no longer checked for replies and
+// archives (or purges) instances older than the archive period of their question.
+func ApplyRetention(now time.Time) error {
+	instances, err := App.store.ListOpenInstances()
+	if err != nil {
+		return err
+	}
+
+	for _, qi := range instances {
+		days := qi.Question.pollDays()
+		posted, err := qi.PostedAt()
+		if days == 0 || err != nil || posted.After(now.AddDate(0, 0, -days)) {
+			continue
+		}
+
+		log.Info("Expiring old instance.", "question", qi.QuestionID, "instance", qi.Timestamp)
+		err = updateInstance(qi.Question.Channel, qi.Timestamp, qi.Question, func(qi *QuestionInstance) error {
+			return qi.Close(StatusExpired)
+		})
+		if err != nil {
+			log.Error("Could not expire instance.", "question", qi.QuestionID, "instance", qi.Timestamp, "err", err)
+		}
+	}
+
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return err
+	}
+
+	for _, question := range questions {
+		days := question.archiveDays()
+		if days == 0 {
+			continue
+		}
+
+		count, err := App.store.ArchiveInstances(question.ID, now.AddDate(0, 0, -days), App.config.PurgeArchived)
+		if err != nil {
+			log.Error("Could not archive instances.", "question", question.ID, "err", err)
+			continue
+		}
+
+		if count > 0 {
+			log.Info("Archived old instances.", "question", question.ID, "count", count, "purged", App.config.PurgeArchived)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+)
+
+type RotationMode string
+
+const (
+	RotationNone        RotationMode = ""            // everyone is asked in every round
+	RotationRoundRobin  RotationMode = "round_robin" // the next participants in a fixed order are asked
+	RotationRandom      RotationMode = "random"      // random participants are asked, nobody twice until everyone had a turn
+	RotationFacilitator RotationMode = "facilitator" // everyone is asked and the next participant in order leads the round
+)
+
+// subset reports whether only the picked participants are asked.
+func (m RotationMode) subset() bool {
+	return m == RotationRoundRobin || m == RotationRandom
+}
+
+func (q *Question) rotationSize() int {
+	if q.Rotation == RotationFacilitator || q.RotationSize < 1 {
+		return 1
+	}
+	return q.RotationSize
+}
+
+// rotate picks the participants of the next round from the rotation queue and removes them from it.
+// Absent users keep their place in the queue and are picked once they are back. When the queue runs
+// out, it is refilled with everyone who was not picked yet, in order or shuffled depending on the mode.
+func (q *Question) rotate(participants []string, absent []string) []string {
+	// users who are no longer participants leave the queue
+	queue := slices.DeleteFunc(slices.Clone(q.RotationQueue), func(user string) bool {
+		return !slices.Contains(participants, user)
+	})
+
+	var picked []string
+	available := func(user string) bool {
+		return !slices.Contains(absent, user) && !slices.Contains(picked, user)
+	}
+
+	for len(picked) < q.rotationSize() {
+		i := slices.IndexFunc(queue, available)
+		if i == -1 {
+			var refill []string
+			for _, user := range participants {
+				if !slices.Contains(queue, user) && !slices.Contains(picked, user) {
+					refill = append(refill, user)
+				}
+			}
+			if !slices.ContainsFunc(refill, available) {
+				break // not enough participants are around
+			}
+
+			if q.Rotation == RotationRandom {
+				rand.Shuffle(len(refill), func(i, j int) {
+					refill[i], refill[j] = refill[j], refill[i]
+				})
+			}
+			queue = append(queue, refill...)
+			continue
+		}
+
+		picked = append(picked, queue[i])
+		queue = slices.Delete(queue, i, i+1)
+	}
+
+	q.RotationQueue = queue
+	return picked
+}
+
+// moveToFront makes the user the next one picked by the rotation.
+func (q *Question) moveToFront(user string) {
+	queue := slices.DeleteFunc(slices.Clone(q.RotationQueue), func(u string) bool {
+		return u == user
+	})
+	q.RotationQueue = append([]string{user}, queue...)
+}
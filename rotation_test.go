@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRotate(t *testing.T) {
+	participants := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name      string
+		mode      RotationMode
+		size      int
+		queue     []string
+		absent    []string
+		want      []string
+		wantQueue []string
+	}{
+		{"first round", RotationRoundRobin, 1, nil, nil, []string{"a"}, []string{"b", "c"}},
+		{"next in queue", RotationRoundRobin, 1, []string{"b", "c"}, nil, []string{"b"}, []string{"c"}},
+		{"refill when the queue runs out", RotationRoundRobin, 2, []string{"c"}, nil, []string{"c", "a"}, []string{"b"}},
+		{"absent user keeps their place", RotationRoundRobin, 1, []string{"a", "b", "c"}, []string{"a"}, []string{"b"}, []string{"a", "c"}},
+		{"refill skips absent users", RotationRoundRobin, 1, []string{"c"}, []string{"c"}, []string{"a"}, []string{"c", "b"}},
+		{"refill with mostly absent users", RotationRoundRobin, 2, nil, []string{"a", "b"}, []string{"c"}, []string{"a", "b"}},
+		{"everyone absent", RotationRoundRobin, 1, nil, []string{"a", "b", "c"}, nil, []string{}},
+		{"former participants leave the queue", RotationRoundRobin, 1, []string{"x", "b"}, nil, []string{"b"}, []string{}},
+		{"size larger than participants", RotationRoundRobin, 5, nil, nil, []string{"a", "b", "c"}, []string{}},
+		{"facilitator picks one", RotationFacilitator, 3, nil, nil, []string{"a"}, []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Question{Rotation: tt.mode, RotationSize: tt.size, RotationQueue: tt.queue}
+			got := q.rotate(participants, tt.absent)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("rotate() = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(q.RotationQueue, tt.wantQueue) {
+				t.Errorf("rotation queue = %v, want %v", q.RotationQueue, tt.wantQueue)
+			}
+		})
+	}
+
+	t.Run("random picks everyone once", func(t *testing.T) {
+		q := Question{Rotation: RotationRandom, RotationSize: 1}
+		var picked []string
+		for range participants {
+			picked = append(picked, q.rotate(participants, nil)...)
+		}
+		slices.Sort(picked)
+		if !slices.Equal(picked, participants) {
+			t.Errorf("random rotation picked %v, want everyone once", picked)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/adhocore/gronx"
+)
+
+type ScheduleKind string
+
+const (
+	ScheduleCron ScheduleKind = ""     // runs repeatedly by the cron expression
+	ScheduleOnce ScheduleKind = "once" // runs once at each of the listed times
+)
+
+func (q *Question) IsOneOff() bool {
+	return q.Schedule == ScheduleOnce
+}
+
+// location returns the time zone in which the cron expression of the question is evaluated,
+// questions without a time zone use the local time zone of the server.
+func (q *Question) location() *time.Location {
+	if q.Timezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		// the time zone is validated when the question is saved, but the tz database can change
+		return time.Local
+	}
+	return loc
+}
+
+// IsDue reports whether the question should run in the minute starting at now,
+// evaluating its cron expression in the time zone of the question.
+//
+// When the clocks jump forward, runs scheduled for the skipped local times happen right
+// at the jump. When the clocks fall back, runs scheduled for the repeated local times
+// only happen on their first occurrence.
+//
+// One-off questions are due once the first of their upcoming runs has come.
+func (q *Question) IsDue(gron *gronx.Gronx, now time.Time) (bool, error) {
+	if q.IsOneOff() {
+		return len(q.RunAt) != 0 && !q.RunAt[0].After(now), nil
+	}
+
+	local := now.In(q.location())
+
+	// start of the current zone period, zero if the zone has no transitions
+	transition, _ := local.ZoneBounds()
+	var shift time.Duration
+	var previousOffset int
+	if !transition.IsZero() {
+		_, offset := local.Zone()
+		_, previousOffset = transition.Add(-time.Second).Zone()
+		shift = time.Duration(previousOffset-offset) * time.Second
+	}
+	sinceTransition := now.Sub(transition)
+
+	if shift > 0 && sinceTransition < shift {
+		// the clocks fell back and this local time has already happened
+		return false, nil
+	}
+
+	due, err := gron.IsDue(q.Cron, local)
+	if err != nil || due {
+		return due, err
+	}
+
+	if shift < 0 && sinceTransition < time.Minute {
+		// the clocks jumped forward, catch up with the local times which were skipped
+		skipped := transition.In(time.FixedZone("", previousOffset))
+		for t := skipped; t.Before(skipped.Add(-shift)); t = t.Add(time.Minute) {
+			due, err = gron.IsDue(q.Cron, t)
+			if err != nil || due {
+				return due, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// NextRun returns the next time the question runs after now, in the time zone of the question.
+func (q *Question) NextRun(now time.Time) (time.Time, error) {
+	if q.IsOneOff() {
+		i := slices.IndexFunc(q.RunAt, func(t time.Time) bool { return t.After(now) })
+		if i == -1 {
+			return time.Time{}, nil
+		}
+		return q.RunAt[i].In(q.location()), nil
+	}
+
+	return gronx.NextTickAfter(q.Cron, now.In(q.location()), false)
+}
+
+// takeDueRuns moves the runs of a one-off question which are due to the past runs,
+// so that each of them happens only once. The question is deactivated after its last run.
+func (q *Question) takeDueRuns(now time.Time) {
+	for len(q.RunAt) != 0 && !q.RunAt[0].After(now) {
+		q.PastRuns = append(q.PastRuns, q.RunAt[0])
+		q.RunAt = q.RunAt[1:]
+	}
+
+	if len(q.RunAt) == 0 {
+		q.RunAt = nil
+		q.IsActive = false
+	}
+}
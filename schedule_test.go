@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adhocore/gronx"
+)
+
+func TestIsDue(t *testing.T) {
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		timezone string
+		cron     string
+		now      string // UTC
+		want     bool
+	}{
+		{"regular time", "Europe/Bratislava", "30 9 * * *", "2026-03-10 08:30", true},
+		{"other minute", "Europe/Bratislava", "30 9 * * *", "2026-03-10 08:31", false},
+		{"no transitions", "UTC", "0 12 * * *", "2026-03-29 12:00", true},
+		// on 2026-03-29 the clocks jump from 02:00 to 03:00 local time at 01:00 UTC
+		{"skipped time runs at the jump", "Europe/Bratislava", "30 2 * * *", "2026-03-29 01:00", true},
+		{"skipped time runs only once", "Europe/Bratislava", "30 2 * * *", "2026-03-29 01:01", false},
+		{"time right after the jump", "Europe/Bratislava", "0 3 * * *", "2026-03-29 01:00", true},
+		{"skipped time of another day", "Europe/Bratislava", "30 2 * * 1", "2026-03-29 01:00", false},
+		// on 2026-10-25 the clocks fall back from 03:00 to 02:00 local time at 01:00 UTC
+		{"repeated time first occurrence", "Europe/Bratislava", "30 2 * * *", "2026-10-25 00:30", true},
+		{"repeated time second occurrence", "Europe/Bratislava", "30 2 * * *", "2026-10-25 01:30", false},
+		{"time after the repeated hour", "Europe/Bratislava", "30 3 * * *", "2026-10-25 02:30", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Question{Timezone: tt.timezone, Cron: tt.cron}
+			got, err := q.IsDue(gronx.New(), utc(tt.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsDue(%q at %s UTC in %s) = %v, want %v", tt.cron, tt.now, tt.timezone, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("one-off", func(t *testing.T) {
+		now := utc("2026-03-10 08:30")
+		runs := []struct {
+			runAt []time.Time
+			want  bool
+		}{
+			{nil, false},
+			{[]time.Time{now.Add(time.Minute)}, false},
+			{[]time.Time{now}, true},
+			{[]time.Time{now.Add(-time.Hour), now.Add(time.Hour)}, true},
+		}
+		for _, run := range runs {
+			q := Question{Schedule: ScheduleOnce, RunAt: run.runAt}
+			got, err := q.IsDue(gronx.New(), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != run.want {
+				t.Errorf("IsDue(%v at %s) = %v, want %v", run.runAt, now, got, run.want)
+			}
+		}
+	})
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"encoding/json"
 	"github.com/adhocore/gronx"
 	"github.com/charmbracelet/log"
-	bolt "go.etcd.io/bbolt"
 	"time"
 )
 
@@ -14,34 +12,63 @@ type scheduler struct {
 }
 
 func (s *scheduler) tickNewQuestions(now time.Time) {
-	var questions []Question
-
-	err := App.db.View(func(tx *bolt.Tx) error {
-		return tx.Bucket([]byte("questions")).ForEach(func(k, v []byte) error {
-			var q Question
-			err := json.Unmarshal(v, &q)
-			if err != nil {
-				return err
-			}
-
-			if q.IsActive {
-				questions = append(questions, q)
-			}
-			return nil
-		})
-	})
+	questions, err := App.store.ListQuestions()
 	if err != nil {
 		s.logger.Error("Cannot list questions.", "err", err)
 	}
 
 	for _, question := range questions {
+		if !question.IsActive || question.IsDeleted() {
+			continue
+		}
+
 		qlog := s.logger.With("question", question.ID)
-		due, err := s.gron.IsDue(question.Cron, now)
+		due, err := question.IsDue(s.gron, now)
 		if err != nil {
 			qlog.Error("Error while checking cron.", "err", err)
 			continue
 		}
 
+		if due && question.IsOneOff() {
+			// one-off runs happen on the chosen day even if it is a holiday
+			question.takeDueRuns(now)
+			err = question.Save()
+			if err != nil {
+				qlog.Error("Could not save question.", "err", err)
+				continue
+			}
+		} else if due {
+			skipped, err := question.skipHoliday(now)
+			if err != nil {
+				qlog.Error("Error while checking holidays.", "err", err)
+			}
+			if skipped {
+				qlog.Info("Skipping run on a holiday.", "shifted", question.ShiftedRun)
+				continue
+			}
+		}
+
+		if !question.ShiftedRun.IsZero() && !question.ShiftedRun.After(now) {
+			// clear the shifted run first, so that a failing run is not retried every minute
+			question.ShiftedRun = time.Time{}
+			err = question.Save()
+			if err != nil {
+				qlog.Error("Could not save question.", "err", err)
+				continue
+			}
+			due = true
+		}
+
+		if due && question.SkipNextRun {
+			question.SkipNextRun = false
+			err = question.Save()
+			if err != nil {
+				qlog.Error("Could not save question.", "err", err)
+			}
+			qlog.Info("Skipping run on request.")
+			continue
+		}
+
 		if due {
 			qlog.Info("Creating new instance of a question.")
 			err = question.NewInstance()
@@ -52,6 +79,13 @@ func (s *scheduler) tickNewQuestions(now time.Time) {
 	}
 }
 
+func (s *scheduler) tickDeadlines(now time.Time) {
+	err := CloseExpiredRounds(now)
+	if err != nil {
+		s.logger.Error("Error while closing rounds after their deadline.", "err", err)
+	}
+}
+
 func (s *scheduler) tickPeriodicCheck(now time.Time) {
 	due, err := s.gron.IsDue(manualCheckCron, now)
 	if err != nil {
@@ -86,6 +120,56 @@ func (s *scheduler) tickPing(now time.Time) {
 	}
 }
 
+func (s *scheduler) tickRetention(now time.Time) {
+	due, err := s.gron.IsDue(retentionCron, now)
+	if err != nil {
+		s.logger.Error("Error while checking cron.", "err", err)
+		return
+	}
+
+	if due {
+		s.logger.Info("Archiving old question instances.")
+		err = ApplyRetention(now)
+		if err != nil {
+			s.logger.Error("Error while archiving instances.", "err", err)
+			return
+		}
+
+		s.logger.Info("Emptying trash.")
+		err = EmptyTrash(now)
+		if err != nil {
+			s.logger.Error("Error while emptying trash.", "err", err)
+		}
+	}
+}
+
+func (s *scheduler) tickBackup(now time.Time) {
+	if App.config.BackupDir == "" {
+		return
+	}
+
+	due, err := s.gron.IsDue(backupCron, now)
+	if err != nil {
+		s.logger.Error("Error while checking cron.", "err", err)
+		return
+	}
+
+	if due {
+		s.logger.Info("Creating database backup.")
+		filename, err := CreateBackup(now)
+		if err != nil {
+			s.logger.Error("Error while creating backup.", "err", err)
+			return
+		}
+		s.logger.Info("Backup created.", "file", filename)
+
+		err = RotateBackups(App.config.BackupKeepDaily, App.config.BackupKeepWeekly)
+		if err != nil {
+			s.logger.Error("Error while rotating backups.", "err", err)
+		}
+	}
+}
+
 func RunScheduler() {
 	defer App.wg.Done()
 
@@ -99,8 +183,11 @@ func RunScheduler() {
 	for {
 		now := time.Now().Truncate(1 * time.Minute)
 		go sched.tickNewQuestions(now)
+		go sched.tickDeadlines(now)
 		go sched.tickPeriodicCheck(now)
 		go sched.tickPing(now)
+		go sched.tickRetention(now)
+		go sched.tickBackup(now)
 		time.Sleep(1 * time.Minute)
 	}
 }
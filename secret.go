@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sealedSecret is a secret encrypted with a random data key, which is itself encrypted
+// with the master key. Rotating the master key only needs to re-encrypt the data key.
+type sealedSecret struct {
+	KeyID      string // fingerprint of the master key used to wrap the data key
+	WrappedKey []byte // data key encrypted with the master key
+	Ciphertext []byte // secret encrypted with the data key
+}
+
+var errNoMasterKey = errors.New("no encryption key configured, set TOKEN_KEY or TOKEN_KEY_FILE")
+
+// loadMasterKey reads a base64 encoded 256-bit key from the given variable or from the file named by <variable>_FILE.
+func loadMasterKey(variable string) ([]byte, error) {
+	encoded := os.Getenv(variable)
+	if filename := os.Getenv(variable + "_FILE"); encoded == "" && filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", strings.ToLower(variable)+"_file", err)
+		}
+		encoded = string(data)
+	}
+
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s should be 32 base64 encoded bytes", strings.ToLower(variable))
+	}
+	return key, nil
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func gcmSeal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func gcmOpen(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// sealSecret encrypts the secret, additionalData binds it to its owner so it cannot be moved to another object.
+func sealSecret(masterKey []byte, secret string, additionalData string) (*sealedSecret, error) {
+	if masterKey == nil {
+		return nil, errNoMasterKey
+	}
+
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := gcmSeal(dataKey, []byte(secret), []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := gcmSeal(masterKey, dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sealedSecret{
+		KeyID:      keyID(masterKey),
+		WrappedKey: wrappedKey,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+func (s *sealedSecret) open(masterKey []byte, additionalData string) (string, error) {
+	dataKey, err := s.dataKey(masterKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := gcmOpen(dataKey, s.Ciphertext, []byte(additionalData))
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func (s *sealedSecret) dataKey(masterKey []byte) ([]byte, error) {
+	if masterKey == nil {
+		return nil, errNoMasterKey
+	}
+	if s.KeyID != keyID(masterKey) {
+		return nil, fmt.Errorf("secret is encrypted with key %s, but key %s is configured", s.KeyID, keyID(masterKey))
+	}
+
+	dataKey, err := gcmOpen(masterKey, s.WrappedKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// rewrap re-encrypts the data key with a new master key, leaving the secret itself untouched.
+func (s *sealedSecret) rewrap(oldKey []byte, newKey []byte) error {
+	dataKey, err := s.dataKey(oldKey)
+	if err != nil {
+		return err
+	}
+
+	wrappedKey, err := gcmSeal(newKey, dataKey, nil)
+	if err != nil {
+		return err
+	}
+
+	s.KeyID = keyID(newKey)
+	s.WrappedKey = wrappedKey
+	return nil
+}
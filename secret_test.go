@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestSealedSecret(t *testing.T) {
+	oldKey, newKey := testKey(1), testKey(2)
+
+	sealed, err := sealSecret(oldKey, "xoxb-secret", "T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("xoxb-secret")) {
+		t.Fatal("sealed secret contains the plaintext")
+	}
+
+	rewrapped := *sealed
+	err = rewrapped.rewrap(oldKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rewrapped.Ciphertext, sealed.Ciphertext) {
+		t.Error("rewrap changed the ciphertext of the secret")
+	}
+
+	tampered := *sealed
+	tampered.WrappedKey = bytes.Clone(sealed.WrappedKey)
+	tampered.WrappedKey[len(tampered.WrappedKey)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		secret  *sealedSecret
+		key     []byte
+		team    string
+		wantErr bool
+	}{
+		{"open", sealed, oldKey, "T1", false},
+		{"wrong key", sealed, newKey, "T1", true},
+		{"no key", sealed, nil, "T1", true},
+		{"wrong team", sealed, oldKey, "T2", true},
+		{"rewrapped with the new key", &rewrapped, newKey, "T1", false},
+		{"rewrapped with the old key", &rewrapped, oldKey, "T1", true},
+		{"rewrapped with the wrong team", &rewrapped, newKey, "T2", true},
+		{"tampered data key", &tampered, oldKey, "T1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := tt.secret.open(tt.key, tt.team)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("open() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && secret != "xoxb-secret" {
+				t.Errorf("open() = %q, want the sealed secret", secret)
+			}
+		})
+	}
+
+	err = sealed.rewrap(newKey, oldKey)
+	if err == nil {
+		t.Error("rewrap() with the wrong old key succeeded")
+	}
+	_, err = sealSecret(nil, "xoxb-secret", "T1")
+	if err == nil {
+		t.Error("sealSecret() without a key succeeded")
+	}
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(testKey(3))
+	filename := filepath.Join(t.TempDir(), "key")
+	err := os.WriteFile(filename, []byte(key+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		file    string
+		want    []byte
+		wantErr bool
+	}{
+		{"not configured", "", "", nil, false},
+		{"variable", key, "", testKey(3), false},
+		{"file", "", filename, testKey(3), false},
+		{"missing file", "", filepath.Join(t.TempDir(), "missing"), nil, true},
+		{"not base64", "klúč", "", nil, true},
+		{"too short", base64.StdEncoding.EncodeToString([]byte("short")), "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_KEY", tt.value)
+			t.Setenv("TEST_KEY_FILE", tt.file)
+			got, err := loadMasterKey("TEST_KEY")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMasterKey() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("loadMasterKey() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/slack-go/slack"
@@ -9,6 +12,7 @@ import (
 
 func ConnectSlack() {
 	App.slack = make(map[string]*slack.Client)
+	App.botUsers = make(map[string]string)
 
 	teams, err := ListTeams()
 	if err != nil {
@@ -54,6 +58,93 @@ func ListChannelMembers(teamID string, channel string) ([]string, error) {
 	return allUsers, nil
 }
 
+func ListUserGroupMembers(teamID string, group string) ([]string, error) {
+	client, ok := App.slack[teamID]
+	if !ok {
+		return []string{}, fmt.Errorf("not connected to team %s", teamID)
+	}
+
+	return client.GetUserGroupMembers(group)
+}
+
+// ListUserGroups returns enabled user groups of the team.
+func ListUserGroups(teamID string) ([]slack.UserGroup, error) {
+	client, ok := App.slack[teamID]
+	if !ok {
+		return nil, fmt.Errorf("not connected to team %s", teamID)
+	}
+
+	return client.GetUserGroups()
+}
+
+const (
+	humanUserTTL   = 24 * time.Hour // how long the flags of a user are cached, they rarely change
+	usersInfoBatch = 50             // users loaded by a single users.info call
+)
+
+type cachedHuman struct {
+	human     bool
+	fetchedAt time.Time
+}
+
+// humanUsers caches whether users are human, keyed by team and user.
+var humanUsers = struct {
+	sync.Mutex
+	flags map[string]cachedHuman
+}{flags: map[string]cachedHuman{}}
+
+// IsHumanUser reports whether the user is neither a bot nor a deactivated account.
+// Users who cannot be loaded are not considered human.
+func IsHumanUser(teamID string, user string) (bool, error) {
+	humans, err := FilterHumanUsers(teamID, []string{user})
+	return len(humans) == 1, err
+}
+
+// FilterHumanUsers returns the users who are neither bots nor deactivated accounts, keeping their order.
+// Users are loaded in bulk and cached. Users who cannot be loaded, for example because of rate limits,
+// are skipped, so that a round is still posted to everyone else.
+func FilterHumanUsers(teamID string, users []string) ([]string, error) {
+	client, ok := App.slack[teamID]
+	if !ok {
+		return nil, fmt.Errorf("not connected to team %s", teamID)
+	}
+
+	humanUsers.Lock()
+	defer humanUsers.Unlock()
+
+	now := time.Now()
+	var missing []string
+	for _, user := range users {
+		cached, ok := humanUsers.flags[teamID+"/"+user]
+		if !ok || now.Sub(cached.fetchedAt) > humanUserTTL {
+			missing = append(missing, user)
+		}
+	}
+
+	for batch := range slices.Chunk(missing, usersInfoBatch) {
+		infos, err := client.GetUsersInfo(batch...)
+		if err != nil {
+			log.Error("Could not load users, skipping them.", "team", teamID, "users", batch, "err", err)
+			continue
+		}
+
+		for _, info := range *infos {
+			// slackbot is not flagged as a bot
+			human := !info.IsBot && !info.Deleted && info.ID != "USLACKBOT"
+			humanUsers.flags[teamID+"/"+info.ID] = cachedHuman{human: human, fetchedAt: now}
+		}
+	}
+
+	var humans []string
+	for _, user := range users {
+		// stale flags are still used when they could not be refreshed
+		if cached, ok := humanUsers.flags[teamID+"/"+user]; ok && cached.human {
+			humans = append(humans, user)
+		}
+	}
+	return humans, nil
+}
+
 func LoadMemberName(teamID string, user string) (string, error) {
 	client, ok := App.slack[teamID]
 	if !ok {
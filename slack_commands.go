@@ -0,0 +1,400 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const commandHelp = "Použitie `/buzerator`:\n" +
+	"• `/buzerator` – odkaz na nastavenia buzerácií v tomto kanáli\n" +
+	"• `/buzerator list` – buzerácie v tomto kanáli a ich ďalšie spustenie\n" +
+	"• `/buzerator status` – kto ešte neodpovedal v prebiehajúcich kolách\n" +
+	"• `/buzerator invoke <id>` – spustiť buzeráciu hneď\n" +
+	"• `/buzerator pause <id>`, `/buzerator resume <id>` – pozastaviť a znovu zapnúť buzeráciu\n" +
+	"• `/buzerator skip <id>` – vynechať najbližšie spustenie\n" +
+	"• `/buzerator remind` – pripomenúť sa hneď tým, ktorí ešte neodpovedali\n" +
+	"• `/buzerator ooo [@človek] 2026-11-02 [2026-11-09]` – nastaviť neprítomnosť\n" +
+	"• `/buzerator restore [id]` – obnoviť buzerácie z koša\n" +
+	"• `/buzerator help` – táto nápoveda"
+
+func handleCommand(evt *socketmode.Event, client *socketmode.Client) {
+	ev, ok := evt.Data.(slack.SlashCommand)
+	if !ok {
+		log.Warn("Invalid event data.", "evt", *evt)
+		return
+	}
+	client.Ack(*evt.Request)
+
+	args := strings.Fields(ev.Text)
+	if len(args) == 0 {
+		sendSettingsLink(ev)
+		return
+	}
+
+	var msg string
+	switch args[0] {
+	case "list":
+		msg = listCommand(ev)
+	case "status":
+		msg = statusCommand(ev)
+	case "invoke":
+		msg = questionCommand(ev, args[1:], invokeQuestion)
+	case "pause":
+		msg = questionCommand(ev, args[1:], pauseQuestion)
+	case "resume":
+		msg = questionCommand(ev, args[1:], resumeQuestion)
+	case "skip":
+		msg = questionCommand(ev, args[1:], skipQuestion)
+	case "remind":
+		msg = remindCommand(ev)
+	case "ooo":
+		msg = oooCommand(ev, args[1:])
+	case "restore":
+		msg = restoreCommand(ev, args[1:])
+	case "help":
+		msg = commandHelp
+	default:
+		msg = fmt.Sprintf("Príkaz `%s` nepoznám.\n\n%s", args[0], commandHelp)
+	}
+
+	_, err := App.slack[ev.TeamID].PostEphemeral(ev.ChannelID, ev.UserID, slack.MsgOptionText(msg, false))
+	if err != nil {
+		log.Error("Could not send command reply.", "err", err)
+	}
+}
+
+// sendSettingsLink replies with a link to the web UI of the channel.
+func sendSettingsLink(ev slack.SlashCommand) {
+	token, err := App.webUI.CreateToken(ev.TeamID, ev.ChannelID, ev.UserID)
+	if err != nil {
+		log.Error("Could not create web UI token.", "err", err)
+		return
+	}
+
+	msg := fmt.Sprintf("Nastavenia tohto kanála nájdeš tu: %s/%s/%s/%s/", App.config.RootURL, ev.TeamID, ev.ChannelID, token)
+	_, err = App.slack[ev.TeamID].PostEphemeral(ev.ChannelID, ev.UserID, slack.MsgOptionText(msg, false))
+	if err != nil {
+		var slackErr slack.SlackErrorResponse
+		ok := errors.As(err, &slackErr)
+
+		if ok && (slackErr.Err == "channel_not_found" || slackErr.Err == "not_in_channel") {
+			log.Warn("Received command from a channel I am not in.", "channel", ev.ChannelID, "user", ev.UserID)
+			_, _, err := App.slack[ev.TeamID].PostMessage(ev.UserID, slack.MsgOptionText("⚠️ Predtým, ako môžeš použiť `/buzerator` v nejakom kanáli, musíš ma doňho pridať.", false))
+			if err != nil {
+				log.Error("Could not send command not_in_channel notice.", "channel", ev.ChannelID, "user", ev.UserID)
+			}
+			return
+		}
+
+		log.Error("Could not send command reply.", "err", err)
+	}
+}
+
+// channelQuestions returns the questions of the channel the command was sent from, without the deleted ones.
+func channelQuestions(ev slack.SlashCommand) ([]Question, error) {
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Question
+	for _, question := range questions {
+		if question.TeamID == ev.TeamID && question.Channel == ev.ChannelID && !question.IsDeleted() {
+			result = append(result, question)
+		}
+	}
+	return result, nil
+}
+
+// summary returns the first line of the question message, shortened.
+func (q *Question) summary() string {
+	return shorten(q.Message, 60)
+}
+
+// shorten returns the first line of the text, cut to at most limit characters.
+func shorten(text string, limit int) string {
+	line, _, _ := strings.Cut(text, "\n")
+	if runes := []rune(line); len(runes) > limit {
+		line = string(runes[:limit]) + "…"
+	}
+	return line
+}
+
+func listCommand(ev slack.SlashCommand) string {
+	questions, err := channelQuestions(ev)
+	if err != nil {
+		log.Error("Could not list questions.", "err", err)
+		return "⚠️ Buzerácie sa nepodarilo načítať."
+	}
+	if len(questions) == 0 {
+		return "V tomto kanáli nie je žiadna buzerácia. Vytvoriť ju môžeš cez `/buzerator`."
+	}
+
+	lines := []string{"Buzerácie v tomto kanáli:"}
+	now := time.Now()
+	for _, question := range questions {
+		var state string
+		nextRun, err := question.NextRun(now)
+		switch {
+		case !question.IsActive:
+			state = "pozastavená"
+		case err != nil || nextRun.IsZero():
+			state = "žiadne ďalšie spustenie"
+		default:
+			state = fmt.Sprintf("ďalšie spustenie <!date^%d^{date_short_pretty} {time}|%s>", nextRun.Unix(), nextRun.Format("2. 1. 2006 15:04 MST"))
+			if question.SkipNextRun {
+				state += ", vynechá sa"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("• `%d` %s – %s", question.ID, question.summary(), state))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// openInstances returns the running rounds of the questions in the channel the command was sent from.
+func openInstances(ev slack.SlashCommand) ([]QuestionInstance, error) {
+	questions, err := channelQuestions(ev)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []QuestionInstance
+	for i := range questions {
+		if questions[i].CurrentInstance == "" {
+			continue
+		}
+
+		qi, err := questions[i].Instance()
+		if err != nil {
+			return nil, err
+		}
+		if qi.QuestionID != 0 && qi.IsOpen() {
+			instances = append(instances, qi)
+		}
+	}
+	return instances, nil
+}
+
+func statusCommand(ev slack.SlashCommand) string {
+	instances, err := openInstances(ev)
+	if err != nil {
+		log.Error("Could not load open rounds.", "err", err)
+		return "⚠️ Kolá sa nepodarilo načítať."
+	}
+	if len(instances) == 0 {
+		return "V tomto kanáli práve neprebieha žiadne kolo."
+	}
+
+	var lines []string
+	for _, qi := range instances {
+		data := qi.messageData()
+		lines = append(lines, fmt.Sprintf("*`%d` %s* – odpovedalo %d z %d", qi.QuestionID, qi.Question.summary(), len(data.Answered), data.Expected))
+		if len(data.Missing) == 0 {
+			lines = append(lines, "🎉 Všetci odpovedali.")
+		} else {
+			lines = append(lines, fmt.Sprintf("❌ %s", strings.Join(data.Missing, ", ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// questionCommand runs an action on the question of the channel with the ID given in the arguments.
+func questionCommand(ev slack.SlashCommand, args []string, action func(q *Question) (string, error)) string {
+	if len(args) != 1 {
+		return commandHelp
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return commandHelp
+	}
+
+	question, err := LoadQuestion(id)
+	if err != nil {
+		log.Error("Could not load question.", "question", id, "err", err)
+		return "⚠️ Buzeráciu sa nepodarilo načítať."
+	}
+	if question.TeamID != ev.TeamID || question.Channel != ev.ChannelID || question.IsDeleted() {
+		return fmt.Sprintf("V tomto kanáli nie je buzerácia %d. Zoznam nájdeš cez `/buzerator list`.", id)
+	}
+
+	msg, err := action(&question)
+	if err != nil {
+		log.Error("Could not run command on question.", "question", id, "command", ev.Text, "err", err)
+		return "⚠️ Príkaz sa nepodarilo vykonať."
+	}
+	return msg
+}
+
+func invokeQuestion(q *Question) (string, error) {
+	err := q.NewInstance()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("🚀 Buzerácia %d je spustená.", q.ID), nil
+}
+
+func pauseQuestion(q *Question) (string, error) {
+	q.IsActive = false
+	return fmt.Sprintf("⏸️ Buzerácia %d je pozastavená.", q.ID), q.Save()
+}
+
+func resumeQuestion(q *Question) (string, error) {
+	q.IsActive = true
+	return fmt.Sprintf("▶️ Buzerácia %d je znovu zapnutá.", q.ID), q.Save()
+}
+
+func skipQuestion(q *Question) (string, error) {
+	q.SkipNextRun = true
+	return fmt.Sprintf("⏭️ Najbližšie spustenie buzerácie %d sa vynechá.", q.ID), q.Save()
+}
+
+func remindCommand(ev slack.SlashCommand) string {
+	instances, err := openInstances(ev)
+	if err != nil {
+		log.Error("Could not load open rounds.", "err", err)
+		return "⚠️ Kolá sa nepodarilo načítať."
+	}
+
+	userChannels := map[string][]string{}
+	for _, qi := range instances {
+		for user, status := range qi.Responses {
+			if status == ResponseMissing && !slices.Contains(userChannels[user], ev.ChannelID) {
+				userChannels[user] = append(userChannels[user], ev.ChannelID)
+			}
+		}
+	}
+	if len(userChannels) == 0 {
+		return "Nie je komu pripomínať, všetci odpovedali. 🎉"
+	}
+
+	sendPings(ev.TeamID, userChannels)
+	return fmt.Sprintf("🔔 Pripomenul som sa ľuďom, ktorí ešte neodpovedali: %d", len(userChannels))
+}
+
+// oooCommand records an absence: /buzerator ooo [@user] FROM [TO].
+// Without arguments it lists the upcoming absences of the user.
+func oooCommand(ev slack.SlashCommand, args []string) string {
+	usage := "Použitie: `/buzerator ooo [@človek] 2026-11-02 [2026-11-09]`"
+
+	user := ev.UserID
+	if len(args) > 0 {
+		if mentioned, ok := parseUserMention(args[0]); ok {
+			user = mentioned
+			args = args[1:]
+		}
+	}
+
+	if len(args) == 0 && user == ev.UserID {
+		return listAbsencesMessage(ev.TeamID, user)
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return usage
+	}
+
+	if user != ev.UserID {
+		manager, err := IsManager(ev.TeamID, ev.UserID)
+		if err != nil {
+			log.Error("Could not check manager.", "team", ev.TeamID, "user", ev.UserID, "err", err)
+			return "⚠️ Neprítomnosť sa nepodarilo uložiť."
+		}
+		if !manager {
+			return "⚠️ Neprítomnosť iným ľuďom môžu nastavovať iba manažéri."
+		}
+	}
+
+	to := ""
+	if len(args) == 2 {
+		to = args[1]
+	}
+	absence, err := NewAbsence(ev.TeamID, user, args[0], to, ev.UserID)
+	if err != nil {
+		return usage
+	}
+
+	err = absence.Save()
+	if err != nil {
+		log.Error("Could not save absence.", "team", ev.TeamID, "user", user, "err", err)
+		return "⚠️ Neprítomnosť sa nepodarilo uložiť."
+	}
+	return fmt.Sprintf("🌴 <@%s> je mimo od %s do %s.", user, absence.From, absence.To)
+}
+
+func listAbsencesMessage(teamID, user string) string {
+	absences, err := App.store.ListAbsences(teamID)
+	if err != nil {
+		log.Error("Could not list absences.", "team", teamID, "err", err)
+		return "⚠️ Neprítomnosti sa nepodarilo načítať."
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	lines := []string{"Tvoje neprítomnosti:"}
+	for _, absence := range absences {
+		if absence.User == user && absence.To >= today {
+			lines = append(lines, fmt.Sprintf("🌴 %s – %s", absence.From, absence.To))
+		}
+	}
+	if len(lines) == 1 {
+		return "Nemáš naplánovanú žiadnu neprítomnosť. Použitie: `/buzerator ooo 2026-11-02 [2026-11-09]`"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseUserMention extracts the user ID from an escaped mention such as <@U123|name>.
+func parseUserMention(s string) (string, bool) {
+	mention, ok := strings.CutPrefix(s, "<@")
+	if !ok || !strings.HasSuffix(mention, ">") {
+		return "", false
+	}
+
+	user, _, _ := strings.Cut(strings.TrimSuffix(mention, ">"), "|")
+	return user, user != ""
+}
+
+// restoreCommand restores deleted questions of the channel, either the one with the given ID or all of them.
+func restoreCommand(ev slack.SlashCommand, args []string) string {
+	if len(args) > 0 {
+		return restoreQuestionCommand(ev, args[0])
+	}
+
+	count, err := restoreQuestionsForChannel(ev.TeamID, ev.ChannelID, true)
+	if err != nil {
+		log.Error("Could not restore questions.", "channel", ev.ChannelID, "team", ev.TeamID, "err", err)
+		return "⚠️ Buzerácie sa nepodarilo obnoviť."
+	}
+	if count == 0 {
+		return "V koši tohto kanála nie je žiadna buzerácia."
+	}
+	return fmt.Sprintf("♻️ Obnovené buzerácie: %d", count)
+}
+
+func restoreQuestionCommand(ev slack.SlashCommand, arg string) string {
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return "Použitie: `/buzerator restore [id]`"
+	}
+
+	question, err := LoadQuestion(id)
+	if err != nil {
+		log.Error("Could not load question.", "question", id, "err", err)
+		return "⚠️ Buzeráciu sa nepodarilo obnoviť."
+	}
+	if question.TeamID != ev.TeamID || question.Channel != ev.ChannelID || !question.IsDeleted() {
+		return fmt.Sprintf("V koši tohto kanála nie je buzerácia %d.", id)
+	}
+
+	err = question.Restore()
+	if err != nil {
+		log.Error("Could not restore question.", "question", id, "err", err)
+		return "⚠️ Buzeráciu sa nepodarilo obnoviť."
+	}
+	return fmt.Sprintf("♻️ Buzerácia %d je obnovená.", id)
+}
@@ -1,15 +1,10 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-
 	"github.com/charmbracelet/log"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
-	bolt "go.etcd.io/bbolt"
 )
 
 func commonSlackHandler() {
@@ -27,7 +22,17 @@ func commonSlackHandler() {
 	socketmodeHandler := socketmode.NewSocketmodeHandler(client)
 	socketmodeHandler.HandleEvents(slackevents.Message, handleMessage)
 	socketmodeHandler.HandleSlashCommand("/buzerator", handleCommand)
+	socketmodeHandler.HandleInteractionBlockAction(answerActionID, handleAnswerAction)
+	socketmodeHandler.HandleInteractionBlockAction(nothingActionID, handleResponseAction)
+	socketmodeHandler.HandleInteractionBlockAction(skipActionID, handleResponseAction)
+	socketmodeHandler.HandleInteractionBlockAction(oooActionID, handleResponseAction)
+	socketmodeHandler.HandleInteractionBlockAction(homeSettingsActionID, handleSettingsAction)
+	socketmodeHandler.HandleInteraction(slack.InteractionTypeViewSubmission, handleViewSubmission)
+	socketmodeHandler.HandleEvents(slackevents.AppHomeOpened, handleAppHomeOpened)
 	socketmodeHandler.HandleEvents(slackevents.ChannelArchive, handleChannelArchive)
+	socketmodeHandler.HandleEvents(slackevents.ChannelUnarchive, handleChannelUnarchive)
+	socketmodeHandler.HandleEvents(slackevents.MemberJoinedChannel, handleMemberJoinedChannel)
+	socketmodeHandler.HandleEvents(slackevents.MemberLeftChannel, handleMemberLeftChannel)
 
 	socketmodeHandler.Handle(socketmode.EventTypeConnecting, handleConnecting)
 	socketmodeHandler.Handle(socketmode.EventTypeConnected, handleConnected)
@@ -81,18 +86,28 @@ func handleMessage(evt *socketmode.Event, client *socketmode.Client) {
 
 	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.MessageEvent)
 	if !ok {
-		log.Warn("Invalid event data.", "ev", *ev)
+		log.Warn("Invalid event data.", "ev", eventsAPIEvent.InnerEvent.Data)
 		return
 	}
 
-	logger := log.With("channel", ev.Channel, "ts", ev.TimeStamp)
+	// for message_changed events the thread and timestamp are only present in the inner message
+	msg := ev.Message
+	logger := log.With("channel", ev.Channel, "ts", msg.Timestamp)
 
-	if ev.ThreadTimeStamp == "" {
+	if msg.ThreadTimestamp == "" || msg.ThreadTimestamp == msg.Timestamp {
 		logger.Debug("Ignoring non-thread message.")
 		return
 	}
 
-	qi, err := LoadQuestionInstance(ev.Channel, ev.ThreadTimeStamp)
+	if ev.SubType != "" && ev.SubType != "message_changed" && ev.SubType != "file_share" && ev.SubType != "thread_broadcast" {
+		logger.Debug("Ignoring message subtype.", "subtype", ev.SubType)
+		return
+	}
+
+	unlock := lockInstance(ev.Channel, msg.ThreadTimestamp)
+	defer unlock()
+
+	qi, err := LoadQuestionInstance(ev.Channel, msg.ThreadTimestamp)
 	if err != nil {
 		logger.Error("Could not load question instance.", "err", err)
 		return
@@ -103,41 +118,36 @@ func handleMessage(evt *socketmode.Event, client *socketmode.Client) {
 		return
 	}
 
-	err = qi.HandleMessage(ev.User, ev.TimeStamp)
+	if ev.SubType == "message_changed" {
+		err = qi.HandleEdit(*msg)
+	} else {
+		err = qi.HandleMessage(*msg)
+	}
 	if err != nil {
 		logger.Error("Error while handling reply.", "err", err, "channel")
 	}
 }
 
-func handleCommand(evt *socketmode.Event, client *socketmode.Client) {
-	ev, ok := evt.Data.(slack.SlashCommand)
+func handleChannelArchive(evt *socketmode.Event, client *socketmode.Client) {
+	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
 	if !ok {
 		log.Warn("Invalid event data.", "evt", *evt)
 		return
 	}
 	client.Ack(*evt.Request)
 
-	token := App.webUI.CreateToken(ev.TeamID, ev.ChannelID)
-	msg := fmt.Sprintf("Nastavenia tohto kanála nájdeš tu: %s/%s/%s/%s/", App.config.RootURL, ev.TeamID, ev.ChannelID, token)
-	_, err := App.slack[ev.TeamID].PostEphemeral(ev.ChannelID, ev.UserID, slack.MsgOptionText(msg, false))
-	if err != nil {
-		var slackErr slack.SlackErrorResponse
-		ok := errors.As(err, &slackErr)
-
-		if ok && (slackErr.Err == "channel_not_found" || slackErr.Err == "not_in_channel") {
-			log.Warn("Received command from a channel I am not in.", "channel", ev.ChannelID, "user", ev.UserID)
-			_, _, err := App.slack[ev.TeamID].PostMessage(ev.UserID, slack.MsgOptionText("⚠️ Predtým, ako môžeš použiť `/buzerator` v nejakom kanáli, musíš ma doňho pridať.", false))
-			if err != nil {
-				log.Error("Could not send command not_in_channel notice.", "channel", ev.ChannelID, "user", ev.UserID)
-			}
-			return
-		}
-
-		log.Error("Could not send command reply.", "err", err)
+	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.ChannelArchiveEvent)
+	if !ok {
+		log.Warn("Invalid event data.", "ev", eventsAPIEvent.InnerEvent.Data)
+		return
 	}
+
+	teamID := eventsAPIEvent.TeamID
+	log.Info("Channel archived, cleaning up questions.", "channel", ev.Channel, "team", teamID, "user", ev.User)
+	trashQuestionsForChannel(teamID, ev.Channel)
 }
 
-func handleChannelArchive(evt *socketmode.Event, client *socketmode.Client) {
+func handleChannelUnarchive(evt *socketmode.Event, client *socketmode.Client) {
 	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
 	if !ok {
 		log.Warn("Invalid event data.", "evt", *evt)
@@ -145,44 +155,67 @@ func handleChannelArchive(evt *socketmode.Event, client *socketmode.Client) {
 	}
 	client.Ack(*evt.Request)
 
-	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.ChannelArchiveEvent)
+	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.ChannelUnarchiveEvent)
 	if !ok {
-		log.Warn("Invalid event data.", "ev", *ev)
+		log.Warn("Invalid event data.", "ev", eventsAPIEvent.InnerEvent.Data)
 		return
 	}
 
 	teamID := eventsAPIEvent.TeamID
-	log.Info("Channel archived, cleaning up questions.", "channel", ev.Channel, "team", teamID, "user", ev.User)
-	cleanupQuestionsForChannel(teamID, ev.Channel)
+	log.Info("Channel unarchived, restoring questions.", "channel", ev.Channel, "team", teamID, "user", ev.User)
+	_, err := restoreQuestionsForChannel(teamID, ev.Channel, false)
+	if err != nil {
+		log.Error("Could not restore questions.", "channel", ev.Channel, "team", teamID, "err", err)
+	}
 }
 
-func cleanupQuestionsForChannel(teamID, channelID string) {
-	var questions []Question
-
-	err := App.db.View(func(tx *bolt.Tx) error {
-		return tx.Bucket([]byte("questions")).ForEach(func(k, v []byte) error {
-			var q Question
-			err := json.Unmarshal(v, &q)
-			if err != nil {
-				return err
-			}
-
-			if q.TeamID == teamID && q.Channel == channelID {
-				questions = append(questions, q)
-			}
-			return nil
-		})
-	})
-	if err != nil {
-		log.Error("Could not list questions for cleanup.", "team", teamID, "channel", channelID, "err", err)
+func handleMemberJoinedChannel(evt *socketmode.Event, client *socketmode.Client) {
+	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+	if !ok {
+		log.Warn("Invalid event data.", "evt", *evt)
 		return
 	}
+	client.Ack(*evt.Request)
 
-	for _, question := range questions {
-		log.Info("Deleting question due to channel archive/bot removal.", "question", question.ID, "team", teamID, "channel", channelID)
-		err := question.Delete()
+	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.MemberJoinedChannelEvent)
+	if !ok {
+		log.Warn("Invalid event data.", "ev", eventsAPIEvent.InnerEvent.Data)
+		return
+	}
+
+	teamID := eventsAPIEvent.TeamID
+	if ev.User != App.botUsers[teamID] {
+		err := memberJoined(teamID, ev.Channel, ev.User)
 		if err != nil {
-			log.Error("Could not delete question during cleanup.", "question", question.ID, "team", teamID, "channel", channelID, "err", err)
+			log.Error("Could not add participant.", "channel", ev.Channel, "team", teamID, "user", ev.User, "err", err)
 		}
+		return
+	}
+
+	log.Info("Added to channel, restoring questions.", "channel", ev.Channel, "team", teamID, "inviter", ev.Inviter)
+	_, err := restoreQuestionsForChannel(teamID, ev.Channel, false)
+	if err != nil {
+		log.Error("Could not restore questions.", "channel", ev.Channel, "team", teamID, "err", err)
+	}
+}
+
+func handleMemberLeftChannel(evt *socketmode.Event, client *socketmode.Client) {
+	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+	if !ok {
+		log.Warn("Invalid event data.", "evt", *evt)
+		return
+	}
+	client.Ack(*evt.Request)
+
+	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.MemberLeftChannelEvent)
+	if !ok {
+		log.Warn("Invalid event data.", "ev", eventsAPIEvent.InnerEvent.Data)
+		return
+	}
+
+	teamID := eventsAPIEvent.TeamID
+	err := memberLeft(teamID, ev.Channel, ev.User)
+	if err != nil {
+		log.Error("Could not remove participant.", "channel", ev.Channel, "team", teamID, "user", ev.User, "err", err)
 	}
 }
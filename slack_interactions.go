@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	answerActionID   = "answer"      // button on the instance message opening the answer form
+	answerCallbackID = "answer_form" // submission of the answer form
+	nothingActionID  = "nothing"     // button on the instance message answering that there is nothing to report
+	skipActionID     = "skip"        // button on the instance message skipping the round
+	oooActionID      = "ooo"         // button on the instance message marking the user out of office today
+)
+
+// homeMetadataSuffix marks answer forms opened from the App Home in their private metadata.
+const homeMetadataSuffix = ":home"
+
+// buttonResponses maps the buttons on the instance message to the response they record.
+var buttonResponses = map[string]ResponseStatus{
+	nothingActionID: ResponseNothing,
+	skipActionID:    ResponseSkipped,
+	oooActionID:     ResponseExcused,
+}
+
+var buttonConfirmations = map[ResponseStatus]string{
+	ResponseNothing: "Zapísal som, že nemáš nič nové. Ďakujem! ❤️",
+	ResponseSkipped: "Toto kolo vynecháš. Ak si to rozmyslíš, stačí napísať update do threadu.",
+	ResponseExcused: "Zapísal som, že si dnes mimo. Pekný deň! 🌴",
+}
+
+// instanceValue identifies an instance as "channel:ts" in values of App Home buttons and in metadata of forms.
+func instanceValue(channel, timestamp string) string {
+	return channel + ":" + timestamp
+}
+
+// parseInstanceValue returns the channel and timestamp of an instance identified by instanceValue.
+func parseInstanceValue(value string) (string, string) {
+	channel, timestamp, _ := strings.Cut(value, ":")
+	return channel, timestamp
+}
+
+// actionInstance returns the channel and timestamp of the instance a button belongs to. Buttons on the
+// instance message belong to the message, buttons on the App Home carry the instanceValue in their value.
+func actionInstance(callback slack.InteractionCallback) (string, string) {
+	if callback.Container.ChannelID != "" {
+		return callback.Container.ChannelID, callback.Container.MessageTs
+	}
+
+	return parseInstanceValue(callback.ActionCallback.BlockActions[0].Value)
+}
+
+func handleAnswerAction(evt *socketmode.Event, client *socketmode.Client) {
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok || len(callback.ActionCallback.BlockActions) == 0 {
+		log.Warn("Invalid event data.", "evt", *evt)
+		return
+	}
+	client.Ack(*evt.Request)
+
+	channel, timestamp := actionInstance(callback)
+	logger := log.With("channel", channel, "ts", timestamp, "user", callback.User.ID)
+
+	qi, err := LoadQuestionInstance(channel, timestamp)
+	if err != nil {
+		logger.Error("Could not load question instance.", "err", err)
+		return
+	}
+	if qi.QuestionID == 0 {
+		logger.Warn("Answer requested for an unknown instance.")
+		return
+	}
+
+	api, ok := App.slack[callback.Team.ID]
+	if !ok {
+		logger.Error("Not connected to team.", "team", callback.Team.ID)
+		return
+	}
+
+	_, err = api.OpenView(callback.TriggerID, answerModal(&qi, callback.Container.Type == "view"))
+	if err != nil {
+		logger.Error("Could not open answer form.", "err", err)
+	}
+}
+
+func handleResponseAction(evt *socketmode.Event, client *socketmode.Client) {
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok || len(callback.ActionCallback.BlockActions) == 0 {
+		log.Warn("Invalid event data.", "evt", *evt)
+		return
+	}
+	client.Ack(*evt.Request)
+
+	status := buttonResponses[callback.ActionCallback.BlockActions[0].ActionID]
+	channel, timestamp := actionInstance(callback)
+	logger := log.With("channel", channel, "ts", timestamp, "user", callback.User.ID, "status", status)
+
+	unlock := lockInstance(channel, timestamp)
+	defer unlock()
+
+	qi, err := LoadQuestionInstance(channel, timestamp)
+	if err != nil {
+		logger.Error("Could not load question instance.", "err", err)
+		return
+	}
+	if qi.QuestionID == 0 {
+		logger.Warn("Button clicked on an unknown instance.")
+		return
+	}
+
+	api, ok := App.slack[callback.Team.ID]
+	if !ok {
+		logger.Error("Not connected to team.", "team", callback.Team.ID)
+		return
+	}
+
+	recorded, err := qi.RespondWithButton(callback.User.ID, status)
+	if err != nil {
+		logger.Error("Error while handling button.", "err", err)
+		return
+	}
+
+	if callback.Container.Type == "view" {
+		// the App Home shows the result instead of an ephemeral message
+		err = publishHome(callback.Team.ID, callback.User.ID)
+		if err != nil {
+			logger.Error("Could not update App Home.", "err", err)
+		}
+		return
+	}
+
+	text := buttonConfirmations[status]
+	if !recorded {
+		text = "Toto kolo je už uzavreté, nie si medzi opýtanými, alebo si už napísal/-a update."
+	}
+	_, err = api.PostEphemeral(channel, callback.User.ID, slack.MsgOptionText(text, false), slack.MsgOptionTS(timestamp))
+	if err != nil {
+		logger.Error("Could not send confirmation.", "err", err)
+	}
+}
+
+// answerPrompts returns the fields of the answer form. Questions without prompts,
+// which can be answered from the App Home, get a single unlabeled field.
+func (q *Question) answerPrompts() []Prompt {
+	if len(q.Prompts) == 0 {
+		return []Prompt{{Required: true}}
+	}
+	return q.Prompts
+}
+
+// answerModal builds the form with one input per prompt of the question. Forms opened from the App Home
+// remember it in their metadata, so that it can be updated once the answer is submitted.
+func answerModal(qi *QuestionInstance, fromHome bool) slack.ModalViewRequest {
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, qi.Question.Message, false, false), nil, nil),
+	}
+
+	for i, prompt := range qi.Question.answerPrompts() {
+		input := slack.NewPlainTextInputBlockElement(nil, "value")
+		input.Multiline = true
+
+		label := prompt.Label
+		if label == "" {
+			label = "Tvoj update"
+		}
+		block := slack.NewInputBlock(promptBlockID(i), slack.NewTextBlockObject(slack.PlainTextType, label, false, false), nil, input)
+		block.Optional = !prompt.Required
+		blocks = append(blocks, block)
+	}
+
+	metadata := instanceValue(qi.Question.Channel, qi.Timestamp)
+	if fromHome {
+		metadata += homeMetadataSuffix
+	}
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      answerCallbackID,
+		PrivateMetadata: metadata,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "Buzerátor", false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, "Odoslať", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Zrušiť", false, false),
+		Blocks:          slack.Blocks{BlockSet: blocks},
+	}
+}
+
+func promptBlockID(i int) string {
+	return fmt.Sprintf("prompt-%d", i)
+}
+
+func handleViewSubmission(evt *socketmode.Event, client *socketmode.Client) {
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok {
+		log.Warn("Invalid event data.", "evt", *evt)
+		return
+	}
+	// acknowledging without a response closes the modal
+	client.Ack(*evt.Request)
+
+	if callback.View.CallbackID != answerCallbackID {
+		return
+	}
+
+	metadata, fromHome := strings.CutSuffix(callback.View.PrivateMetadata, homeMetadataSuffix)
+	channel, timestamp := parseInstanceValue(metadata)
+	logger := log.With("channel", channel, "ts", timestamp, "user", callback.User.ID)
+
+	// the reply posted on behalf of the user triggers a message event for the same instance
+	unlock := lockInstance(channel, timestamp)
+	defer unlock()
+
+	qi, err := LoadQuestionInstance(channel, timestamp)
+	if err != nil {
+		logger.Error("Could not load question instance.", "err", err)
+		return
+	}
+	if qi.QuestionID == 0 {
+		logger.Warn("Answer submitted for an unknown instance.")
+		return
+	}
+
+	answer := FormAnswer{User: callback.User.ID}
+	for i, prompt := range qi.Question.answerPrompts() {
+		answer.Fields = append(answer.Fields, AnswerField{
+			Prompt: prompt.Label,
+			Text:   strings.TrimSpace(callback.View.State.Values[promptBlockID(i)]["value"].Value),
+		})
+	}
+
+	err = qi.HandleFormAnswer(answer)
+	if err != nil {
+		logger.Error("Error while handling answer.", "err", err)
+		return
+	}
+
+	if fromHome {
+		// the App Home would still list the round
+		err = publishHome(callback.Team.ID, callback.User.ID)
+		if err != nil {
+			logger.Error("Could not update App Home.", "err", err)
+		}
+	}
+}
@@ -20,10 +20,11 @@ func ConnectTeam(team Team) error {
 		slack.OptionAppLevelToken(App.config.SlackAppToken),
 	)
 
-	_, err := api.AuthTest()
+	auth, err := api.AuthTest()
 	if err != nil {
 		return err
 	}
 	App.slack[team.ID] = api
+	App.botUsers[team.ID] = auth.UserID
 	return nil
 }
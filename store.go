@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Store is the persistence layer of the application.
+//
+// Loading an object which does not exist returns its zero value without an error.
+type Store interface {
+	ListQuestions() ([]Question, error)
+	LoadQuestion(id uint64) (Question, error)
+	// SaveQuestion stores the question, assigning it a new ID if it does not have one yet.
+	SaveQuestion(q *Question) error
+	// DeleteQuestion removes the question together with all of its instances.
+	DeleteQuestion(id uint64) error
+
+	ListInstances() ([]QuestionInstance, error)
+	// ListQuestionInstances returns instances of a single question posted after since, oldest first.
+	// Use zero time to list all of them.
+	ListQuestionInstances(questionID uint64, since time.Time) ([]QuestionInstance, error)
+	// ListOpenInstances returns instances with the open status, with the question already loaded.
+	ListOpenInstances() ([]QuestionInstance, error)
+	LoadInstance(channel string, timestamp string) (QuestionInstance, error)
+	SaveInstance(qi *QuestionInstance) error
+	DeleteInstance(channel string, timestamp string) error
+	// ArchiveInstances moves instances of the question posted before the given time to the archive,
+	// or deletes them if purge is set. It returns the number of affected instances.
+	ArchiveInstances(questionID uint64, before time.Time, purge bool) (int, error)
+	// ListArchivedInstances returns the archived instances of all questions.
+	ListArchivedInstances() ([]QuestionInstance, error)
+	// SaveArchivedInstance stores the instance directly in the archive.
+	SaveArchivedInstance(qi *QuestionInstance) error
+
+	ListTeams() ([]Team, error)
+	SaveTeam(t *Team) error
+
+	// ListAbsences returns all absences in the team, ordered by ID.
+	ListAbsences(teamID string) ([]Absence, error)
+	// SaveAbsence stores the absence, assigning it a new ID if it does not have one yet.
+	SaveAbsence(a *Absence) error
+	DeleteAbsence(teamID string, id uint64) error
+
+	// ListHolidays returns all holidays in the team, ordered by date.
+	ListHolidays(teamID string) ([]Holiday, error)
+	// SaveHoliday stores the holiday, assigning it a new ID if it does not have one yet.
+	SaveHoliday(h *Holiday) error
+	DeleteHoliday(teamID string, id uint64) error
+
+	// ListTemplates returns all question templates defined in the team, ordered by name.
+	ListTemplates(teamID string) ([]QuestionTemplate, error)
+	// SaveTemplate stores the template, assigning it a new ID if it does not have one yet.
+	SaveTemplate(t *QuestionTemplate) error
+	DeleteTemplate(teamID string, id uint64) error
+
+	// ListUserSettings returns the settings of all users in the team who changed them, ordered by user.
+	ListUserSettings(teamID string) ([]UserSettings, error)
+	LoadUserSettings(teamID string, user string) (UserSettings, error)
+	SaveUserSettings(s *UserSettings) error
+
+	LoadSession(token string) (WebToken, error)
+	SaveSession(session WebToken) error
+	DeleteSessionsBefore(t time.Time) error
+
+	// Backup writes a consistent snapshot of the database while it is in use.
+	Backup(w io.Writer) (int64, error)
+	// Check verifies the integrity of the database file.
+	Check() error
+
+	// SchemaVersion returns the number of the last migration applied to the database.
+	SchemaVersion() (int, error)
+	// Migrate applies all pending migrations, each in its own transaction.
+	Migrate() error
+	Close() error
+}
+
+const (
+	DriverBolt   = "bolt"
+	DriverSQLite = "sqlite"
+)
+
+// OpenStore opens the database without migrating it.
+func OpenStore(driver string, filename string) (Store, error) {
+	return openStore(driver, filename, false)
+}
+
+// OpenStoreReadOnly opens an existing database without writing anything to it, not even the files
+// of the SQLite write-ahead log. All changes of the returned store fail.
+func OpenStoreReadOnly(driver string, filename string) (Store, error) {
+	return openStore(driver, filename, true)
+}
+
+func openStore(driver string, filename string, readOnly bool) (Store, error) {
+	switch driver {
+	case DriverBolt:
+		return openBoltStore(filename, readOnly)
+	case DriverSQLite:
+		return openSQLiteStore(filename, readOnly)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+}
+
+// CopyStore copies all teams, absences, holidays, templates, user settings, questions and their instances,
+// including the archived ones, from one store to another.
+// Web UI sessions are short-lived and are not copied.
+func CopyStore(from Store, to Store) error {
+	teams, err := from.ListTeams()
+	if err != nil {
+		return fmt.Errorf("could not list teams: %w", err)
+	}
+	for i := range teams {
+		err = to.SaveTeam(&teams[i])
+		if err != nil {
+			return fmt.Errorf("could not save team %s: %w", teams[i].ID, err)
+		}
+
+		absences, err := from.ListAbsences(teams[i].ID)
+		if err != nil {
+			return fmt.Errorf("could not list absences of team %s: %w", teams[i].ID, err)
+		}
+		for j := range absences {
+			err = to.SaveAbsence(&absences[j])
+			if err != nil {
+				return fmt.Errorf("could not save absence %d: %w", absences[j].ID, err)
+			}
+		}
+
+		holidays, err := from.ListHolidays(teams[i].ID)
+		if err != nil {
+			return fmt.Errorf("could not list holidays of team %s: %w", teams[i].ID, err)
+		}
+		for j := range holidays {
+			err = to.SaveHoliday(&holidays[j])
+			if err != nil {
+				return fmt.Errorf("could not save holiday %s: %w", holidays[j].Date, err)
+			}
+		}
+
+		templates, err := from.ListTemplates(teams[i].ID)
+		if err != nil {
+			return fmt.Errorf("could not list templates of team %s: %w", teams[i].ID, err)
+		}
+		for j := range templates {
+			err = to.SaveTemplate(&templates[j])
+			if err != nil {
+				return fmt.Errorf("could not save template %d: %w", templates[j].ID, err)
+			}
+		}
+
+		settings, err := from.ListUserSettings(teams[i].ID)
+		if err != nil {
+			return fmt.Errorf("could not list user settings of team %s: %w", teams[i].ID, err)
+		}
+		for j := range settings {
+			err = to.SaveUserSettings(&settings[j])
+			if err != nil {
+				return fmt.Errorf("could not save settings of user %s: %w", settings[j].User, err)
+			}
+		}
+	}
+
+	questions, err := from.ListQuestions()
+	if err != nil {
+		return fmt.Errorf("could not list questions: %w", err)
+	}
+	questionsByID := map[uint64]*Question{}
+	for i := range questions {
+		err = to.SaveQuestion(&questions[i])
+		if err != nil {
+			return fmt.Errorf("could not save question %d: %w", questions[i].ID, err)
+		}
+		questionsByID[questions[i].ID] = &questions[i]
+	}
+
+	instances, err := from.ListInstances()
+	if err != nil {
+		return fmt.Errorf("could not list question instances: %w", err)
+	}
+	for _, qi := range instances {
+		question, ok := questionsByID[qi.QuestionID]
+		if !ok {
+			continue
+		}
+
+		qi.Question = question
+		err = to.SaveInstance(&qi)
+		if err != nil {
+			return fmt.Errorf("could not save question instance %s: %w", qi.Timestamp, err)
+		}
+	}
+
+	archived, err := from.ListArchivedInstances()
+	if err != nil {
+		return fmt.Errorf("could not list archived instances: %w", err)
+	}
+	for _, qi := range archived {
+		question, ok := questionsByID[qi.QuestionID]
+		if !ok {
+			continue
+		}
+
+		qi.Question = question
+		err = to.SaveArchivedInstance(&qi)
+		if err != nil {
+			return fmt.Errorf("could not save archived instance %s: %w", qi.Timestamp, err)
+		}
+	}
+
+	return nil
+}
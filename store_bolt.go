@@ -0,0 +1,836 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltStore struct {
+	db *bolt.DB
+}
+
+func openBoltStore(filename string, readOnly bool) (*boltStore, error) {
+	// do not wait forever when another process (such as a running server) holds the database
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) SchemaVersion() (int, error) {
+	version := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = boltSchemaVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+func boltSchemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket([]byte("meta"))
+	if meta == nil {
+		return 0, nil
+	}
+
+	data := meta.Get([]byte("version"))
+	if data == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(data))
+}
+
+func (s *boltStore) Migrate() error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	pending, err := pendingMigrations(version)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		logMigration(m)
+		err = s.db.Update(func(tx *bolt.Tx) error {
+			err := m.Bolt(tx)
+			if err != nil {
+				return err
+			}
+
+			meta, err := tx.CreateBucketIfNotExists([]byte("meta"))
+			if err != nil {
+				return err
+			}
+			return meta.Put([]byte("version"), []byte(strconv.Itoa(m.Version)))
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *boltStore) Backup(w io.Writer) (int64, error) {
+	var n int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+func (s *boltStore) Check() error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		var errs []error
+		for err := range tx.Check() {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+func questionKey(id uint64) []byte {
+	return []byte(strconv.FormatUint(id, 10))
+}
+
+func instanceKey(channel string, timestamp string) []byte {
+	return []byte(fmt.Sprintf("%s:%s", channel, timestamp))
+}
+
+func (s *boltStore) ListQuestions() ([]Question, error) {
+	var questions []Question
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("questions")).ForEach(func(k, v []byte) error {
+			var q Question
+			err := json.Unmarshal(v, &q)
+			if err != nil {
+				return err
+			}
+
+			questions = append(questions, q)
+			return nil
+		})
+	})
+
+	return questions, err
+}
+
+func (s *boltStore) LoadQuestion(id uint64) (Question, error) {
+	var q Question
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("questions")).Get(questionKey(id))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &q)
+	})
+	return q, err
+}
+
+func (s *boltStore) SaveQuestion(q *Question) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		questionsBucket := tx.Bucket([]byte("questions"))
+
+		if q.ID == 0 {
+			id, err := questionsBucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			q.ID = id
+		} else if q.ID > questionsBucket.Sequence() {
+			// keep the sequence ahead of questions saved with an explicit ID
+			err := questionsBucket.SetSequence(q.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+
+		return questionsBucket.Put(questionKey(q.ID), data)
+	})
+}
+
+func (s *boltStore) DeleteQuestion(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var q Question
+		data := tx.Bucket([]byte("questions")).Get(questionKey(id))
+		if data == nil {
+			return nil
+		}
+		err := json.Unmarshal(data, &q)
+		if err != nil {
+			return err
+		}
+
+		instances := tx.Bucket([]byte("instances"))
+		questionInstances := instances.Bucket(questionKey(id))
+		if questionInstances != nil {
+			threads := tx.Bucket([]byte("threads"))
+			err = questionInstances.ForEach(func(k, v []byte) error {
+				return threads.Delete(instanceKey(q.Channel, string(k)))
+			})
+			if err != nil {
+				return err
+			}
+
+			err = instances.DeleteBucket(questionKey(id))
+			if err != nil {
+				return err
+			}
+		}
+
+		archive := tx.Bucket([]byte("archive"))
+		if archive.Bucket(questionKey(id)) != nil {
+			err = archive.DeleteBucket(questionKey(id))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Bucket([]byte("open_instances")).Delete(questionKey(id))
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("questions")).Delete(questionKey(id))
+	})
+}
+
+// loadBoltInstance loads an instance from the bucket of its question, returning nil if there is no such instance.
+func loadBoltInstance(tx *bolt.Tx, questionID []byte, timestamp string) (*QuestionInstance, error) {
+	questionInstances := tx.Bucket([]byte("instances")).Bucket(questionID)
+	if questionInstances == nil {
+		return nil, nil
+	}
+
+	data := questionInstances.Get([]byte(timestamp))
+	if data == nil {
+		return nil, nil
+	}
+
+	var qi QuestionInstance
+	err := json.Unmarshal(data, &qi)
+	return &qi, err
+}
+
+func (s *boltStore) ListInstances() ([]QuestionInstance, error) {
+	var instances []QuestionInstance
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("instances")).ForEachBucket(func(k []byte) error {
+			return tx.Bucket([]byte("instances")).Bucket(k).ForEach(func(k, v []byte) error {
+				var qi QuestionInstance
+				err := json.Unmarshal(v, &qi)
+				if err != nil {
+					return err
+				}
+
+				instances = append(instances, qi)
+				return nil
+			})
+		})
+	})
+
+	return instances, err
+}
+
+func (s *boltStore) ListQuestionInstances(questionID uint64, since time.Time) ([]QuestionInstance, error) {
+	var instances []QuestionInstance
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		questionInstances := tx.Bucket([]byte("instances")).Bucket(questionKey(questionID))
+		if questionInstances == nil {
+			return nil
+		}
+
+		// instances are keyed by their slack timestamp, so the key order is chronological
+		c := questionInstances.Cursor()
+		for k, v := c.Seek([]byte(slackTimestamp(since))); k != nil; k, v = c.Next() {
+			var qi QuestionInstance
+			err := json.Unmarshal(v, &qi)
+			if err != nil {
+				return err
+			}
+
+			instances = append(instances, qi)
+		}
+		return nil
+	})
+
+	return instances, err
+}
+
+func (s *boltStore) ListOpenInstances() ([]QuestionInstance, error) {
+	var instances []QuestionInstance
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("open_instances")).ForEach(func(k, v []byte) error {
+			var q Question
+			data := tx.Bucket([]byte("questions")).Get(k)
+			if data == nil {
+				return nil
+			}
+			err := json.Unmarshal(data, &q)
+			if err != nil {
+				return err
+			}
+
+			qi, err := loadBoltInstance(tx, k, string(v))
+			if err != nil || qi == nil {
+				return err
+			}
+
+			qi.Question = &q
+			instances = append(instances, *qi)
+			return nil
+		})
+	})
+
+	return instances, err
+}
+
+func (s *boltStore) LoadInstance(channel string, timestamp string) (QuestionInstance, error) {
+	var qi QuestionInstance
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		questionID := tx.Bucket([]byte("threads")).Get(instanceKey(channel, timestamp))
+		if questionID == nil {
+			return nil
+		}
+
+		instance, err := loadBoltInstance(tx, questionID, timestamp)
+		if err != nil || instance == nil {
+			return err
+		}
+
+		qi = *instance
+		return nil
+	})
+	return qi, err
+}
+
+func (s *boltStore) SaveInstance(qi *QuestionInstance) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(qi)
+		if err != nil {
+			return err
+		}
+
+		questionInstances, err := tx.Bucket([]byte("instances")).CreateBucketIfNotExists(questionKey(qi.QuestionID))
+		if err != nil {
+			return err
+		}
+
+		err = questionInstances.Put([]byte(qi.Timestamp), data)
+		if err != nil {
+			return err
+		}
+
+		// a question has at most one open instance, the newest one wins
+		openInstances := tx.Bucket([]byte("open_instances"))
+		if qi.Status == StatusOpen {
+			err = openInstances.Put(questionKey(qi.QuestionID), []byte(qi.Timestamp))
+		} else if string(openInstances.Get(questionKey(qi.QuestionID))) == qi.Timestamp {
+			err = openInstances.Delete(questionKey(qi.QuestionID))
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("threads")).Put(instanceKey(qi.Question.Channel, qi.Timestamp), questionKey(qi.QuestionID))
+	})
+}
+
+func (s *boltStore) DeleteInstance(channel string, timestamp string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		threads := tx.Bucket([]byte("threads"))
+		questionID := threads.Get(instanceKey(channel, timestamp))
+		if questionID == nil {
+			return nil
+		}
+		// the key is only valid for the lifetime of the transaction and until the bucket is modified
+		questionID = slices.Clone(questionID)
+
+		questionInstances := tx.Bucket([]byte("instances")).Bucket(questionID)
+		if questionInstances != nil {
+			err := questionInstances.Delete([]byte(timestamp))
+			if err != nil {
+				return err
+			}
+		}
+
+		openInstances := tx.Bucket([]byte("open_instances"))
+		if string(openInstances.Get(questionID)) == timestamp {
+			err := openInstances.Delete(questionID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return threads.Delete(instanceKey(channel, timestamp))
+	})
+}
+
+func (s *boltStore) ArchiveInstances(questionID uint64, before time.Time, purge bool) (int, error) {
+	count := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		questionInstances := tx.Bucket([]byte("instances")).Bucket(questionKey(questionID))
+		if questionInstances == nil {
+			return nil
+		}
+
+		var q Question
+		err := json.Unmarshal(tx.Bucket([]byte("questions")).Get(questionKey(questionID)), &q)
+		if err != nil {
+			return err
+		}
+
+		var archive *bolt.Bucket
+		if !purge {
+			archive, err = tx.Bucket([]byte("archive")).CreateBucketIfNotExists(questionKey(questionID))
+			if err != nil {
+				return err
+			}
+		}
+
+		threads := tx.Bucket([]byte("threads"))
+		openInstances := tx.Bucket([]byte("open_instances"))
+		end := []byte(slackTimestamp(before))
+
+		// collect the keys first, the bucket must not be modified while iterating it
+		var timestamps [][]byte
+		c := questionInstances.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.Next() {
+			timestamps = append(timestamps, slices.Clone(k))
+		}
+
+		for _, ts := range timestamps {
+			if archive != nil {
+				err = archive.Put(ts, slices.Clone(questionInstances.Get(ts)))
+				if err != nil {
+					return err
+				}
+			}
+
+			err = questionInstances.Delete(ts)
+			if err != nil {
+				return err
+			}
+
+			err = threads.Delete(instanceKey(q.Channel, string(ts)))
+			if err != nil {
+				return err
+			}
+
+			if bytes.Equal(openInstances.Get(questionKey(questionID)), ts) {
+				err = openInstances.Delete(questionKey(questionID))
+				if err != nil {
+					return err
+				}
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+func (s *boltStore) ListArchivedInstances() ([]QuestionInstance, error) {
+	var instances []QuestionInstance
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("archive")).ForEachBucket(func(k []byte) error {
+			return tx.Bucket([]byte("archive")).Bucket(k).ForEach(func(k, v []byte) error {
+				var qi QuestionInstance
+				err := json.Unmarshal(v, &qi)
+				if err != nil {
+					return err
+				}
+
+				instances = append(instances, qi)
+				return nil
+			})
+		})
+	})
+
+	return instances, err
+}
+
+func (s *boltStore) SaveArchivedInstance(qi *QuestionInstance) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(qi)
+		if err != nil {
+			return err
+		}
+
+		archive, err := tx.Bucket([]byte("archive")).CreateBucketIfNotExists(questionKey(qi.QuestionID))
+		if err != nil {
+			return err
+		}
+		return archive.Put([]byte(qi.Timestamp), data)
+	})
+}
+
+func (s *boltStore) ListTeams() ([]Team, error) {
+	teams := []Team{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("teams")).ForEach(func(k, v []byte) error {
+			var team Team
+			err := json.Unmarshal(v, &team)
+			if err != nil {
+				return err
+			}
+
+			teams = append(teams, team)
+			return nil
+		})
+	})
+
+	return teams, err
+}
+
+func (s *boltStore) SaveTeam(t *Team) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("teams")).Put([]byte(t.ID), data)
+	})
+}
+
+func (s *boltStore) ListAbsences(teamID string) ([]Absence, error) {
+	var absences []Absence
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		teamAbsences := tx.Bucket([]byte("absences")).Bucket([]byte(teamID))
+		if teamAbsences == nil {
+			return nil
+		}
+
+		return teamAbsences.ForEach(func(k, v []byte) error {
+			var absence Absence
+			err := json.Unmarshal(v, &absence)
+			if err != nil {
+				return err
+			}
+
+			absences = append(absences, absence)
+			return nil
+		})
+	})
+
+	// keys are decimal, so they do not sort numerically
+	slices.SortFunc(absences, func(a, b Absence) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return absences, err
+}
+
+func (s *boltStore) SaveAbsence(a *Absence) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		teamAbsences, err := tx.Bucket([]byte("absences")).CreateBucketIfNotExists([]byte(a.TeamID))
+		if err != nil {
+			return err
+		}
+
+		if a.ID == 0 {
+			a.ID, err = teamAbsences.NextSequence()
+			if err != nil {
+				return err
+			}
+		} else if a.ID > teamAbsences.Sequence() {
+			err = teamAbsences.SetSequence(a.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+
+		return teamAbsences.Put(questionKey(a.ID), data)
+	})
+}
+
+func (s *boltStore) DeleteAbsence(teamID string, id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		teamAbsences := tx.Bucket([]byte("absences")).Bucket([]byte(teamID))
+		if teamAbsences == nil {
+			return nil
+		}
+		return teamAbsences.Delete(questionKey(id))
+	})
+}
+
+func (s *boltStore) ListHolidays(teamID string) ([]Holiday, error) {
+	var holidays []Holiday
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		teamHolidays := tx.Bucket([]byte("holidays")).Bucket([]byte(teamID))
+		if teamHolidays == nil {
+			return nil
+		}
+
+		return teamHolidays.ForEach(func(k, v []byte) error {
+			var holiday Holiday
+			err := json.Unmarshal(v, &holiday)
+			if err != nil {
+				return err
+			}
+
+			holidays = append(holidays, holiday)
+			return nil
+		})
+	})
+
+	slices.SortFunc(holidays, func(a, b Holiday) int {
+		return cmp.Or(cmp.Compare(a.Date, b.Date), cmp.Compare(a.ID, b.ID))
+	})
+	return holidays, err
+}
+
+func (s *boltStore) SaveHoliday(h *Holiday) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		teamHolidays, err := tx.Bucket([]byte("holidays")).CreateBucketIfNotExists([]byte(h.TeamID))
+		if err != nil {
+			return err
+		}
+
+		if h.ID == 0 {
+			h.ID, err = teamHolidays.NextSequence()
+			if err != nil {
+				return err
+			}
+		} else if h.ID > teamHolidays.Sequence() {
+			err = teamHolidays.SetSequence(h.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+
+		return teamHolidays.Put(questionKey(h.ID), data)
+	})
+}
+
+func (s *boltStore) DeleteHoliday(teamID string, id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		teamHolidays := tx.Bucket([]byte("holidays")).Bucket([]byte(teamID))
+		if teamHolidays == nil {
+			return nil
+		}
+		return teamHolidays.Delete(questionKey(id))
+	})
+}
+
+func (s *boltStore) ListTemplates(teamID string) ([]QuestionTemplate, error) {
+	var templates []QuestionTemplate
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		teamTemplates := tx.Bucket([]byte("templates")).Bucket([]byte(teamID))
+		if teamTemplates == nil {
+			return nil
+		}
+
+		return teamTemplates.ForEach(func(k, v []byte) error {
+			var template QuestionTemplate
+			err := json.Unmarshal(v, &template)
+			if err != nil {
+				return err
+			}
+
+			templates = append(templates, template)
+			return nil
+		})
+	})
+
+	slices.SortFunc(templates, func(a, b QuestionTemplate) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return templates, err
+}
+
+func (s *boltStore) SaveTemplate(t *QuestionTemplate) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		teamTemplates, err := tx.Bucket([]byte("templates")).CreateBucketIfNotExists([]byte(t.TeamID))
+		if err != nil {
+			return err
+		}
+
+		if t.ID == 0 {
+			t.ID, err = teamTemplates.NextSequence()
+			if err != nil {
+				return err
+			}
+		} else if t.ID > teamTemplates.Sequence() {
+			err = teamTemplates.SetSequence(t.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+
+		return teamTemplates.Put(questionKey(t.ID), data)
+	})
+}
+
+func (s *boltStore) DeleteTemplate(teamID string, id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		teamTemplates := tx.Bucket([]byte("templates")).Bucket([]byte(teamID))
+		if teamTemplates == nil {
+			return nil
+		}
+		return teamTemplates.Delete(questionKey(id))
+	})
+}
+
+func (s *boltStore) ListUserSettings(teamID string) ([]UserSettings, error) {
+	var settings []UserSettings
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		teamSettings := tx.Bucket([]byte("user_settings")).Bucket([]byte(teamID))
+		if teamSettings == nil {
+			return nil
+		}
+
+		// keys are user identifiers, so the settings come out ordered by user
+		return teamSettings.ForEach(func(k, v []byte) error {
+			var userSettings UserSettings
+			err := json.Unmarshal(v, &userSettings)
+			if err != nil {
+				return err
+			}
+
+			settings = append(settings, userSettings)
+			return nil
+		})
+	})
+	return settings, err
+}
+
+func (s *boltStore) LoadUserSettings(teamID string, user string) (UserSettings, error) {
+	var settings UserSettings
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		teamSettings := tx.Bucket([]byte("user_settings")).Bucket([]byte(teamID))
+		if teamSettings == nil {
+			return nil
+		}
+
+		data := teamSettings.Get([]byte(user))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &settings)
+	})
+	return settings, err
+}
+
+func (s *boltStore) SaveUserSettings(settings *UserSettings) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		teamSettings, err := tx.Bucket([]byte("user_settings")).CreateBucketIfNotExists([]byte(settings.TeamID))
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+
+		return teamSettings.Put([]byte(settings.User), data)
+	})
+}
+
+func (s *boltStore) LoadSession(token string) (WebToken, error) {
+	var session WebToken
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("sessions")).Get([]byte(token))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &session)
+	})
+	return session, err
+}
+
+func (s *boltStore) SaveSession(session WebToken) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("sessions")).Put([]byte(session.Token), data)
+	})
+}
+
+func (s *boltStore) DeleteSessionsBefore(t time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket([]byte("sessions"))
+		expired := [][]byte{}
+		err := sessions.ForEach(func(k, v []byte) error {
+			var session WebToken
+			err := json.Unmarshal(v, &session)
+			if err != nil {
+				return err
+			}
+
+			if session.CreatedAt.Before(t) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			err := sessions.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,623 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteStore keeps every object as a JSON document next to the columns
+// needed for lookups, so ad-hoc reports can use json_extract on the data column.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(filename string, readOnly bool) (*sqliteStore, error) {
+	if readOnly {
+		// switching to the write-ahead log and creating the meta table would both write to the file
+		db, err := sql.Open("sqlite", "file:"+filename+"?mode=ro&_pragma=busy_timeout(5000)")
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(1)
+		return &sqliteStore{db: db}, nil
+	}
+
+	db, err := sql.Open("sqlite", "file:"+filename+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// a single connection serializes writers the same way bbolt does
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS meta (key TEXT PRIMARY KEY, value TEXT NOT NULL)")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow("SELECT CAST(value AS INTEGER) FROM meta WHERE key = 'version'").Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (s *sqliteStore) Migrate() error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	pending, err := pendingMigrations(version)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		logMigration(m)
+		err = s.migrate(m)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) migrate(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.SQLite(tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO meta (key, value) VALUES ('version', ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value", m.Version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Backup(w io.Writer) (int64, error) {
+	dir, err := os.MkdirTemp("", "buzerator-backup")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, "snapshot.sqlite")
+	_, err = s.db.Exec("VACUUM INTO ?", snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return io.Copy(w, f)
+}
+
+func (s *sqliteStore) Check() error {
+	var result string
+	err := s.db.QueryRow("PRAGMA integrity_check").Scan(&result)
+	if err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// queryJSON runs the query and unmarshals the single data column of every row using fn.
+func (s *sqliteStore) queryJSON(fn func(data []byte) error, query string, args ...any) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		err = rows.Scan(&data)
+		if err != nil {
+			return err
+		}
+
+		err = fn(data)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// getJSON unmarshals the data column of a single row into v, leaving v untouched if there is no such row.
+func (s *sqliteStore) getJSON(v any, query string, args ...any) error {
+	var data []byte
+	err := s.db.QueryRow(query, args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *sqliteStore) ListQuestions() ([]Question, error) {
+	var questions []Question
+	err := s.queryJSON(func(data []byte) error {
+		var q Question
+		err := json.Unmarshal(data, &q)
+		if err != nil {
+			return err
+		}
+
+		questions = append(questions, q)
+		return nil
+	}, "SELECT data FROM questions ORDER BY id")
+	return questions, err
+}
+
+func (s *sqliteStore) LoadQuestion(id uint64) (Question, error) {
+	var q Question
+	err := s.getJSON(&q, "SELECT data FROM questions WHERE id = ?", id)
+	return q, err
+}
+
+func (s *sqliteStore) SaveQuestion(q *Question) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if q.ID == 0 {
+		result, err := tx.Exec("INSERT INTO questions (team_id, channel, data) VALUES (?, ?, '{}')", q.TeamID, q.Channel)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		q.ID = uint64(id)
+	}
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO questions (id, team_id, channel, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET team_id = excluded.team_id, channel = excluded.channel, data = excluded.data`,
+		q.ID, q.TeamID, q.Channel, data)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) DeleteQuestion(id uint64) error {
+	_, err := s.db.Exec("DELETE FROM questions WHERE id = ?", id)
+	return err
+}
+
+func (s *sqliteStore) ListInstances() ([]QuestionInstance, error) {
+	var instances []QuestionInstance
+	err := s.queryJSON(func(data []byte) error {
+		var qi QuestionInstance
+		err := json.Unmarshal(data, &qi)
+		if err != nil {
+			return err
+		}
+
+		instances = append(instances, qi)
+		return nil
+	}, "SELECT data FROM instances ORDER BY ts")
+	return instances, err
+}
+
+func (s *sqliteStore) ListQuestionInstances(questionID uint64, since time.Time) ([]QuestionInstance, error) {
+	var instances []QuestionInstance
+	err := s.queryJSON(func(data []byte) error {
+		var qi QuestionInstance
+		err := json.Unmarshal(data, &qi)
+		if err != nil {
+			return err
+		}
+
+		instances = append(instances, qi)
+		return nil
+	}, "SELECT data FROM instances WHERE question_id = ? AND ts >= ? ORDER BY ts", questionID, slackTimestamp(since))
+	return instances, err
+}
+
+func (s *sqliteStore) ListOpenInstances() ([]QuestionInstance, error) {
+	rows, err := s.db.Query(`SELECT q.data, i.data FROM instances i
+		JOIN questions q ON q.id = i.question_id
+		WHERE json_extract(i.data, '$.Status') = 'open'
+		ORDER BY q.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var instances []QuestionInstance
+	for rows.Next() {
+		var questionData, instanceData []byte
+		err = rows.Scan(&questionData, &instanceData)
+		if err != nil {
+			return nil, err
+		}
+
+		var q Question
+		err = json.Unmarshal(questionData, &q)
+		if err != nil {
+			return nil, err
+		}
+
+		var qi QuestionInstance
+		err = json.Unmarshal(instanceData, &qi)
+		if err != nil {
+			return nil, err
+		}
+
+		qi.Question = &q
+		instances = append(instances, qi)
+	}
+	return instances, rows.Err()
+}
+
+func (s *sqliteStore) LoadInstance(channel string, timestamp string) (QuestionInstance, error) {
+	var qi QuestionInstance
+	err := s.getJSON(&qi, "SELECT data FROM instances WHERE channel = ? AND ts = ?", channel, timestamp)
+	return qi, err
+}
+
+func (s *sqliteStore) SaveInstance(qi *QuestionInstance) error {
+	data, err := json.Marshal(qi)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO instances (channel, ts, question_id, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (channel, ts) DO UPDATE SET question_id = excluded.question_id, data = excluded.data`,
+		qi.Question.Channel, qi.Timestamp, qi.QuestionID, data)
+	return err
+}
+
+func (s *sqliteStore) DeleteInstance(channel string, timestamp string) error {
+	_, err := s.db.Exec("DELETE FROM instances WHERE channel = ? AND ts = ?", channel, timestamp)
+	return err
+}
+
+func (s *sqliteStore) ArchiveInstances(questionID uint64, before time.Time, purge bool) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if !purge {
+		_, err = tx.Exec(`INSERT INTO archived_instances (channel, ts, question_id, data)
+			SELECT channel, ts, question_id, data FROM instances WHERE question_id = ? AND ts < ?
+			ON CONFLICT (channel, ts) DO UPDATE SET data = excluded.data`,
+			questionID, slackTimestamp(before))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM instances WHERE question_id = ? AND ts < ?", questionID, slackTimestamp(before))
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(count), tx.Commit()
+}
+
+func (s *sqliteStore) ListArchivedInstances() ([]QuestionInstance, error) {
+	var instances []QuestionInstance
+	err := s.queryJSON(func(data []byte) error {
+		var qi QuestionInstance
+		err := json.Unmarshal(data, &qi)
+		if err != nil {
+			return err
+		}
+
+		instances = append(instances, qi)
+		return nil
+	}, "SELECT data FROM archived_instances ORDER BY question_id, ts")
+	return instances, err
+}
+
+func (s *sqliteStore) SaveArchivedInstance(qi *QuestionInstance) error {
+	data, err := json.Marshal(qi)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO archived_instances (channel, ts, question_id, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (channel, ts) DO UPDATE SET question_id = excluded.question_id, data = excluded.data`,
+		qi.Question.Channel, qi.Timestamp, qi.QuestionID, data)
+	return err
+}
+
+func (s *sqliteStore) ListTeams() ([]Team, error) {
+	teams := []Team{}
+	err := s.queryJSON(func(data []byte) error {
+		var team Team
+		err := json.Unmarshal(data, &team)
+		if err != nil {
+			return err
+		}
+
+		teams = append(teams, team)
+		return nil
+	}, "SELECT data FROM teams ORDER BY id")
+	return teams, err
+}
+
+func (s *sqliteStore) SaveTeam(t *Team) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("INSERT INTO teams (id, data) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data", t.ID, data)
+	return err
+}
+
+func (s *sqliteStore) ListAbsences(teamID string) ([]Absence, error) {
+	var absences []Absence
+	err := s.queryJSON(func(data []byte) error {
+		var absence Absence
+		err := json.Unmarshal(data, &absence)
+		if err != nil {
+			return err
+		}
+
+		absences = append(absences, absence)
+		return nil
+	}, "SELECT data FROM absences WHERE team_id = ? ORDER BY id", teamID)
+	return absences, err
+}
+
+func (s *sqliteStore) SaveAbsence(a *Absence) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if a.ID == 0 {
+		result, err := tx.Exec("INSERT INTO absences (team_id, user_id, data) VALUES (?, ?, '{}')", a.TeamID, a.User)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		a.ID = uint64(id)
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO absences (id, team_id, user_id, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET team_id = excluded.team_id, user_id = excluded.user_id, data = excluded.data`,
+		a.ID, a.TeamID, a.User, data)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) DeleteAbsence(teamID string, id uint64) error {
+	_, err := s.db.Exec("DELETE FROM absences WHERE team_id = ? AND id = ?", teamID, id)
+	return err
+}
+
+func (s *sqliteStore) ListHolidays(teamID string) ([]Holiday, error) {
+	var holidays []Holiday
+	err := s.queryJSON(func(data []byte) error {
+		var holiday Holiday
+		err := json.Unmarshal(data, &holiday)
+		if err != nil {
+			return err
+		}
+
+		holidays = append(holidays, holiday)
+		return nil
+	}, "SELECT data FROM holidays WHERE team_id = ? ORDER BY day, id", teamID)
+	return holidays, err
+}
+
+func (s *sqliteStore) SaveHoliday(h *Holiday) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if h.ID == 0 {
+		result, err := tx.Exec("INSERT INTO holidays (team_id, day, data) VALUES (?, ?, '{}')", h.TeamID, h.Date)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		h.ID = uint64(id)
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO holidays (id, team_id, day, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET team_id = excluded.team_id, day = excluded.day, data = excluded.data`,
+		h.ID, h.TeamID, h.Date, data)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) DeleteHoliday(teamID string, id uint64) error {
+	_, err := s.db.Exec("DELETE FROM holidays WHERE team_id = ? AND id = ?", teamID, id)
+	return err
+}
+
+func (s *sqliteStore) ListTemplates(teamID string) ([]QuestionTemplate, error) {
+	var templates []QuestionTemplate
+	err := s.queryJSON(func(data []byte) error {
+		var template QuestionTemplate
+		err := json.Unmarshal(data, &template)
+		if err != nil {
+			return err
+		}
+
+		templates = append(templates, template)
+		return nil
+	}, "SELECT data FROM templates WHERE team_id = ? ORDER BY name, id", teamID)
+	return templates, err
+}
+
+func (s *sqliteStore) SaveTemplate(t *QuestionTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if t.ID == 0 {
+		result, err := tx.Exec("INSERT INTO templates (team_id, name, data) VALUES (?, ?, '{}')", t.TeamID, t.Name)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		t.ID = uint64(id)
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO templates (id, team_id, name, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET team_id = excluded.team_id, name = excluded.name, data = excluded.data`,
+		t.ID, t.TeamID, t.Name, data)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) DeleteTemplate(teamID string, id uint64) error {
+	_, err := s.db.Exec("DELETE FROM templates WHERE team_id = ? AND id = ?", teamID, id)
+	return err
+}
+
+func (s *sqliteStore) ListUserSettings(teamID string) ([]UserSettings, error) {
+	var settings []UserSettings
+	err := s.queryJSON(func(data []byte) error {
+		var userSettings UserSettings
+		err := json.Unmarshal(data, &userSettings)
+		if err != nil {
+			return err
+		}
+
+		settings = append(settings, userSettings)
+		return nil
+	}, "SELECT data FROM user_settings WHERE team_id = ? ORDER BY user_id", teamID)
+	return settings, err
+}
+
+func (s *sqliteStore) LoadUserSettings(teamID string, user string) (UserSettings, error) {
+	var settings UserSettings
+	err := s.getJSON(&settings, "SELECT data FROM user_settings WHERE team_id = ? AND user_id = ?", teamID, user)
+	return settings, err
+}
+
+func (s *sqliteStore) SaveUserSettings(settings *UserSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO user_settings (team_id, user_id, data) VALUES (?, ?, ?)
+		ON CONFLICT (team_id, user_id) DO UPDATE SET data = excluded.data`,
+		settings.TeamID, settings.User, data)
+	return err
+}
+
+func (s *sqliteStore) LoadSession(token string) (WebToken, error) {
+	var session WebToken
+	err := s.getJSON(&session, "SELECT data FROM sessions WHERE token = ?", token)
+	return session, err
+}
+
+func (s *sqliteStore) SaveSession(session WebToken) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO sessions (token, created_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (token) DO UPDATE SET created_at = excluded.created_at, data = excluded.data`,
+		session.Token, session.CreatedAt.Unix(), data)
+	return err
+}
+
+func (s *sqliteStore) DeleteSessionsBefore(t time.Time) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE created_at < ?", t.Unix())
+	return err
+}
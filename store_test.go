@@ -0,0 +1,401 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var storeDrivers = []string{DriverBolt, DriverSQLite}
+
+// openTestStore opens a new migrated database in a temporary directory.
+func openTestStore(t *testing.T, driver string) Store {
+	t.Helper()
+
+	store, err := OpenStore(driver, filepath.Join(t.TempDir(), "buzerator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	err = store.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// instanceTimestamps returns the sorted timestamps of the instances.
+func instanceTimestamps(instances []QuestionInstance) []string {
+	var timestamps []string
+	for _, qi := range instances {
+		timestamps = append(timestamps, qi.Timestamp)
+	}
+	slices.Sort(timestamps)
+	return timestamps
+}
+
+func TestCopyStoreArchive(t *testing.T) {
+	posted := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	for _, from := range storeDrivers {
+		for _, to := range storeDrivers {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				source := openTestStore(t, from)
+				q := Question{TeamID: "T1", Channel: "C1", Cron: "0 9 * * *"}
+				err := source.SaveQuestion(&q)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for day := range 3 {
+					qi := QuestionInstance{
+						Question:   &q,
+						QuestionID: q.ID,
+						Timestamp:  slackTimestamp(posted.AddDate(0, 0, day)),
+						Status:     StatusClosed,
+						Responses:  map[string]ResponseStatus{"U1": ResponseAnswered},
+					}
+					err = source.SaveInstance(&qi)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				count, err := source.ArchiveInstances(q.ID, posted.AddDate(0, 0, 2), false)
+				if err != nil || count != 2 {
+					t.Fatalf("ArchiveInstances() = %d, %v, want 2 archived", count, err)
+				}
+
+				destination := openTestStore(t, to)
+				err = CopyStore(source, destination)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				archived, err := destination.ListArchivedInstances()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := []string{slackTimestamp(posted), slackTimestamp(posted.AddDate(0, 0, 1))}
+				if got := instanceTimestamps(archived); !slices.Equal(got, want) {
+					t.Errorf("archived instances after copying = %v, want %v", got, want)
+				}
+				for _, qi := range archived {
+					if qi.Responses["U1"] != ResponseAnswered {
+						t.Errorf("archived instance %s lost its responses: %v", qi.Timestamp, qi.Responses)
+					}
+				}
+
+				instances, err := destination.ListInstances()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := instanceTimestamps(instances); !slices.Equal(got, []string{slackTimestamp(posted.AddDate(0, 0, 2))}) {
+					t.Errorf("instances after copying = %v, want only the last one", got)
+				}
+			})
+		}
+	}
+}
+
+// storeChecks are run against every driver, so that the stores behave the same.
+var storeChecks = []struct {
+	name  string
+	check func(t *testing.T, store Store)
+}{
+	{"questions", checkStoreQuestions},
+	{"instances", checkStoreInstances},
+	{"archive", checkStoreArchive},
+	{"team data", checkStoreTeamData},
+	{"sessions", checkStoreSessions},
+}
+
+func TestStore(t *testing.T) {
+	for _, driver := range storeDrivers {
+		for _, c := range storeChecks {
+			t.Run(driver+" "+c.name, func(t *testing.T) {
+				c.check(t, openTestStore(t, driver))
+			})
+		}
+	}
+}
+
+func checkStoreQuestions(t *testing.T, store Store) {
+	missing, err := store.LoadQuestion(42)
+	if err != nil || missing.ID != 0 {
+		t.Fatalf("LoadQuestion() of a missing question = %+v, %v, want zero value", missing, err)
+	}
+
+	first := Question{TeamID: "T1", Channel: "C1", Message: "Ako sa darí?"}
+	second := Question{TeamID: "T2", Channel: "C2", Message: "Čo nové?"}
+	for _, q := range []*Question{&first, &second} {
+		err = store.SaveQuestion(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.ID == 0 || second.ID == first.ID {
+		t.Fatalf("SaveQuestion() assigned IDs %d and %d", first.ID, second.ID)
+	}
+
+	first.Message = "Ako sa máte?"
+	err = store.SaveQuestion(&first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.LoadQuestion(first.ID)
+	if err != nil || loaded.Message != first.Message || loaded.TeamID != "T1" {
+		t.Errorf("LoadQuestion() = %+v, %v, want the updated question", loaded, err)
+	}
+
+	questions, err := store.ListQuestions()
+	if err != nil || len(questions) != 2 {
+		t.Errorf("ListQuestions() = %d questions, %v, want 2", len(questions), err)
+	}
+
+	err = store.DeleteQuestion(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	questions, err = store.ListQuestions()
+	if err != nil || len(questions) != 1 || questions[0].ID != first.ID {
+		t.Errorf("ListQuestions() after deleting = %+v, %v, want only the first question", questions, err)
+	}
+}
+
+func checkStoreInstances(t *testing.T, store Store) {
+	posted := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	q := Question{TeamID: "T1", Channel: "C1"}
+	err := store.SaveQuestion(&q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for day := range 3 {
+		qi := QuestionInstance{
+			Question:   &q,
+			QuestionID: q.ID,
+			Timestamp:  slackTimestamp(posted.AddDate(0, 0, day)),
+			Status:     StatusClosed,
+			Responses:  map[string]ResponseStatus{"U1": ResponseAnswered, "U2": ResponseMissing},
+		}
+		if day == 2 {
+			qi.Status = StatusOpen
+		}
+		err = store.SaveInstance(&qi)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	last := slackTimestamp(posted.AddDate(0, 0, 2))
+
+	loaded, err := store.LoadInstance("C1", last)
+	if err != nil || loaded.QuestionID != q.ID || loaded.Responses["U2"] != ResponseMissing {
+		t.Errorf("LoadInstance() = %+v, %v, want the saved instance", loaded, err)
+	}
+	missing, err := store.LoadInstance("C2", last)
+	if err != nil || missing.QuestionID != 0 {
+		t.Errorf("LoadInstance() in another channel = %+v, %v, want zero value", missing, err)
+	}
+
+	instances, err := store.ListQuestionInstances(q.ID, posted.AddDate(0, 0, 1))
+	want := []string{slackTimestamp(posted.AddDate(0, 0, 1)), last}
+	if got := instanceTimestamps(instances); err != nil || !slices.Equal(got, want) {
+		t.Errorf("ListQuestionInstances() = %v, %v, want %v", got, err, want)
+	}
+
+	open, err := store.ListOpenInstances()
+	if err != nil || len(open) != 1 || open[0].Timestamp != last || open[0].Question == nil || open[0].Question.ID != q.ID {
+		t.Errorf("ListOpenInstances() = %+v, %v, want the last instance with its question", open, err)
+	}
+
+	loaded.Question = &q
+	loaded.Status = StatusClosed
+	err = store.SaveInstance(&loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err = store.ListOpenInstances()
+	if err != nil || len(open) != 0 {
+		t.Errorf("ListOpenInstances() after closing = %+v, %v, want none", open, err)
+	}
+
+	err = store.DeleteInstance("C1", last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances, err = store.ListInstances()
+	if err != nil || len(instances) != 2 {
+		t.Errorf("ListInstances() after deleting = %d instances, %v, want 2", len(instances), err)
+	}
+
+	err = store.DeleteQuestion(q.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances, err = store.ListInstances()
+	if err != nil || len(instances) != 0 {
+		t.Errorf("ListInstances() after deleting the question = %d instances, %v, want none", len(instances), err)
+	}
+}
+
+func checkStoreArchive(t *testing.T, store Store) {
+	posted := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	q := Question{TeamID: "T1", Channel: "C1"}
+	err := store.SaveQuestion(&q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for day := range 4 {
+		qi := QuestionInstance{Question: &q, QuestionID: q.ID, Timestamp: slackTimestamp(posted.AddDate(0, 0, day)), Status: StatusClosed}
+		err = store.SaveInstance(&qi)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		before       time.Time
+		purge        bool
+		wantCount    int
+		wantArchived int
+		wantLeft     int
+	}{
+		{posted, false, 0, 0, 4},
+		{posted.AddDate(0, 0, 2), false, 2, 2, 2},
+		{posted.AddDate(0, 0, 3), true, 1, 2, 1},
+		{posted.AddDate(0, 0, 3), false, 0, 2, 1},
+	}
+	for _, tt := range tests {
+		count, err := store.ArchiveInstances(q.ID, tt.before, tt.purge)
+		if err != nil || count != tt.wantCount {
+			t.Fatalf("ArchiveInstances(%s, %v) = %d, %v, want %d", tt.before, tt.purge, count, err, tt.wantCount)
+		}
+
+		archived, err := store.ListArchivedInstances()
+		if err != nil || len(archived) != tt.wantArchived {
+			t.Errorf("ListArchivedInstances() = %d instances, %v, want %d", len(archived), err, tt.wantArchived)
+		}
+		instances, err := store.ListInstances()
+		if err != nil || len(instances) != tt.wantLeft {
+			t.Errorf("ListInstances() = %d instances, %v, want %d", len(instances), err, tt.wantLeft)
+		}
+	}
+
+	archived, err := store.LoadInstance("C1", slackTimestamp(posted))
+	if err != nil || archived.QuestionID != 0 {
+		t.Errorf("LoadInstance() of an archived instance = %+v, %v, want zero value", archived, err)
+	}
+}
+
+func checkStoreTeamData(t *testing.T, store Store) {
+	err := store.SaveTeam(&Team{ID: "T1", Name: "Trojsten"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teams, err := store.ListTeams()
+	if err != nil || len(teams) != 1 || teams[0].Name != "Trojsten" {
+		t.Errorf("ListTeams() = %+v, %v, want the saved team", teams, err)
+	}
+
+	for _, a := range []Absence{{TeamID: "T1", User: "U2"}, {TeamID: "T1", User: "U1"}, {TeamID: "T2", User: "U3"}} {
+		err = store.SaveAbsence(&a)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	absences, err := store.ListAbsences("T1")
+	if err != nil || len(absences) != 2 || absences[0].User != "U2" || absences[0].ID >= absences[1].ID {
+		t.Errorf("ListAbsences() = %+v, %v, want two absences ordered by ID", absences, err)
+	}
+	err = store.DeleteAbsence("T1", absences[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	absences, err = store.ListAbsences("T1")
+	if err != nil || len(absences) != 1 || absences[0].User != "U1" {
+		t.Errorf("ListAbsences() after deleting = %+v, %v, want only U1", absences, err)
+	}
+
+	for _, h := range []Holiday{{TeamID: "T1", Date: "2026-12-25"}, {TeamID: "T1", Date: "2026-12-24"}, {TeamID: "T2", Date: "2026-01-01"}} {
+		err = store.SaveHoliday(&h)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	holidays, err := store.ListHolidays("T1")
+	if err != nil || len(holidays) != 2 || holidays[0].Date != "2026-12-24" {
+		t.Errorf("ListHolidays() = %+v, %v, want two holidays ordered by date", holidays, err)
+	}
+	err = store.DeleteHoliday("T1", holidays[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	holidays, err = store.ListHolidays("T1")
+	if err != nil || len(holidays) != 1 || holidays[0].Date != "2026-12-25" {
+		t.Errorf("ListHolidays() after deleting = %+v, %v, want only 2026-12-25", holidays, err)
+	}
+
+	for _, tmpl := range []QuestionTemplate{{TeamID: "T1", Name: "Retro"}, {TeamID: "T1", Name: "Nálada"}} {
+		err = store.SaveTemplate(&tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	templates, err := store.ListTemplates("T1")
+	if err != nil || len(templates) != 2 || templates[0].Name != "Nálada" {
+		t.Errorf("ListTemplates() = %+v, %v, want two templates ordered by name", templates, err)
+	}
+	err = store.DeleteTemplate("T1", templates[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err = store.ListTemplates("T1")
+	if err != nil || len(templates) != 1 || templates[0].Name != "Retro" {
+		t.Errorf("ListTemplates() after deleting = %+v, %v, want only Retro", templates, err)
+	}
+
+	err = store.SaveUserSettings(&UserSettings{TeamID: "T1", User: "U1", MuteReminders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := store.LoadUserSettings("T1", "U1")
+	if err != nil || !settings.MuteReminders {
+		t.Errorf("LoadUserSettings() = %+v, %v, want muted reminders", settings, err)
+	}
+	settings, err = store.LoadUserSettings("T2", "U1")
+	if err != nil || settings.MuteReminders {
+		t.Errorf("LoadUserSettings() in another team = %+v, %v, want zero value", settings, err)
+	}
+	all, err := store.ListUserSettings("T1")
+	if err != nil || len(all) != 1 {
+		t.Errorf("ListUserSettings() = %+v, %v, want one", all, err)
+	}
+}
+
+func checkStoreSessions(t *testing.T, store Store) {
+	now := time.Now().Truncate(time.Second)
+	for _, session := range []WebToken{{Token: "old", CreatedAt: now.Add(-2 * time.Hour)}, {Token: "new", CreatedAt: now, Team: "T1"}} {
+		err := store.SaveSession(session)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := store.DeleteSessionsBefore(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := store.LoadSession("old")
+	if err != nil || old.Token != "" {
+		t.Errorf("LoadSession() of an expired session = %+v, %v, want zero value", old, err)
+	}
+	session, err := store.LoadSession("new")
+	if err != nil || session.Team != "T1" || !session.CreatedAt.Equal(now) {
+		t.Errorf("LoadSession() = %+v, %v, want the saved session", session, err)
+	}
+}
@@ -1,49 +1,63 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
 
 	"github.com/charmbracelet/log"
-	"go.etcd.io/bbolt"
 )
 
 type Team struct {
-	ID    string
-	Name  string
-	Token string
+	ID          string
+	Name        string
+	Token       string        `json:",omitempty"` // bot token, stored in plaintext only when no encryption key is configured
+	SealedToken *sealedSecret `json:",omitempty"` // encrypted bot token
 }
 
+// ListTeams returns all teams with their tokens decrypted.
+// Teams stored with a plaintext token are encrypted on the fly if an encryption key is configured.
 func ListTeams() ([]Team, error) {
-	teams := []Team{}
+	teams, err := App.store.ListTeams()
+	if err != nil {
+		return nil, err
+	}
 
-	err := App.db.View(func(tx *bbolt.Tx) error {
-		teamsBucket := tx.Bucket([]byte("teams"))
-		return teamsBucket.ForEach(func(k, v []byte) error {
-			var team Team
-			err := json.Unmarshal(v, &team)
+	for i := range teams {
+		team := &teams[i]
+		if team.SealedToken != nil {
+			team.Token, err = team.SealedToken.open(App.config.TokenKey, team.ID)
 			if err != nil {
-				return err
+				return nil, fmt.Errorf("team %s: %w", team.ID, err)
 			}
+			continue
+		}
 
-			teams = append(teams, team)
-			return nil
-		})
-	})
+		if App.config.TokenKey != nil && team.Token != "" {
+			log.Info("Encrypting plaintext token.", "team", team.ID)
+			err = team.Save()
+			if err != nil {
+				return nil, fmt.Errorf("could not encrypt token of team %s: %w", team.ID, err)
+			}
+		}
+	}
 
-	return teams, err
+	return teams, nil
 }
 
+// Save stores the team, encrypting its token if an encryption key is configured.
 func (t *Team) Save() error {
-	return App.db.Update(func(tx *bbolt.Tx) error {
-		teamsBucket := tx.Bucket([]byte("teams"))
+	stored := *t
+	stored.SealedToken = nil
 
-		data, err := json.Marshal(t)
+	if App.config.TokenKey != nil {
+		var err error
+		stored.SealedToken, err = sealSecret(App.config.TokenKey, t.Token, t.ID)
 		if err != nil {
 			return err
 		}
+		stored.Token = ""
+	}
 
-		return teamsBucket.Put([]byte(t.ID), data)
-	})
+	return App.store.SaveTeam(&stored)
 }
 
 func (t *Team) Connect() {
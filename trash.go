@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// purgeAt returns when the deleted question is removed permanently, zero time means never.
+func (q *Question) purgeAt() time.Time {
+	if !q.IsDeleted() || App.config.TrashDays == 0 {
+		return time.Time{}
+	}
+	return q.DeletedAt.AddDate(0, 0, App.config.TrashDays)
+}
+
+// EmptyTrash permanently removes questions which have been in the trash for longer than the grace period.
+func EmptyTrash(now time.Time) error {
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return err
+	}
+
+	for _, question := range questions {
+		purgeAt := question.purgeAt()
+		if purgeAt.IsZero() || purgeAt.After(now) {
+			continue
+		}
+
+		log.Info("Purging deleted question.", "question", question.ID, "deleted", question.DeletedAt)
+		err = question.Purge()
+		if err != nil {
+			log.Error("Could not purge question.", "question", question.ID, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// trashQuestionsForChannel moves all questions of the channel to the trash.
+func trashQuestionsForChannel(teamID, channelID string) {
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		log.Error("Could not list questions for cleanup.", "team", teamID, "channel", channelID, "err", err)
+		return
+	}
+
+	for _, question := range questions {
+		if question.TeamID != teamID || question.Channel != channelID || question.IsDeleted() {
+			continue
+		}
+
+		log.Info("Moving question to trash due to channel archive/bot removal.", "question", question.ID, "team", teamID, "channel", channelID)
+		err := question.Delete(DeletedChannelGone)
+		if err != nil {
+			log.Error("Could not delete question during cleanup.", "question", question.ID, "team", teamID, "channel", channelID, "err", err)
+		}
+	}
+}
+
+// restoreQuestionsForChannel restores questions of the channel from the trash and returns how many were restored.
+// Unless all is set, only questions deleted because the channel went away are restored.
+func restoreQuestionsForChannel(teamID, channelID string, all bool) (int, error) {
+	questions, err := App.store.ListQuestions()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, question := range questions {
+		if question.TeamID != teamID || question.Channel != channelID || !question.IsDeleted() {
+			continue
+		}
+		if !all && question.DeleteReason != DeletedChannelGone {
+			continue
+		}
+
+		log.Info("Restoring question from trash.", "question", question.ID, "team", teamID, "channel", channelID)
+		err = question.Restore()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
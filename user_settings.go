@@ -0,0 +1,25 @@
+package main
+
+// UserSettings are the personal notification preferences of a user in a team.
+// The zero value keeps all notifications on, so users who never changed them need no record.
+type UserSettings struct {
+	TeamID        string // slack team identifier
+	User          string // slack user identifier
+	MuteReminders bool   // no direct messages about rounds the user did not answer yet
+	MuteQuorum    bool   // no direct messages when a question owned by the user reaches its quorum
+}
+
+// LoadUserSettings returns the settings of the user, or the default ones if they never changed them.
+func LoadUserSettings(teamID, user string) (UserSettings, error) {
+	settings, err := App.store.LoadUserSettings(teamID, user)
+	if err != nil {
+		return settings, err
+	}
+
+	settings.TeamID, settings.User = teamID, user
+	return settings, nil
+}
+
+func (s *UserSettings) Save() error {
+	return App.store.SaveUserSettings(s)
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"crypto/subtle"
 	"embed"
-	"encoding/json"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"net/http"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/adhocore/gronx"
@@ -17,7 +20,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/slack-go/slack"
-	bolt "go.etcd.io/bbolt"
 )
 
 type WebToken struct {
@@ -25,11 +27,13 @@ type WebToken struct {
 	CreatedAt time.Time
 	Channel   string
 	Team      string
+	User      string // slack user who requested the link, empty for older sessions
 }
 
-type webUI struct {
-	tokens []WebToken
-}
+// webTokenLifetime is how long a link generated by the slash command stays valid.
+const webTokenLifetime = 1 * time.Hour
+
+type webUI struct{}
 
 //go:embed static/*
 var staticFiles embed.FS
@@ -57,6 +61,7 @@ func ServeUI() {
 	r.StaticFS("/static/", http.FS(staticFs))
 	r.GET("/", ui.handleIndex)
 	r.GET("/callback/", ui.handleCallback)
+	r.GET("/admin/backup/", ui.checkAdminToken, ui.handleBackup)
 
 	g := r.Group("/:team/:channel/:token/", ui.checkToken)
 	g.GET("/", ui.handleQuestionList)
@@ -65,6 +70,20 @@ func ServeUI() {
 	g.GET("/edit/:id/", ui.handleEditQuestion)
 	g.POST("/edit/:id/", ui.handleEditQuestionPost)
 	g.POST("/invoke/:id/", ui.handleInvokeQuestion)
+	g.POST("/close/:id/", ui.handleCloseQuestion)
+	g.POST("/delete/:id/", ui.handleDeleteQuestion)
+	g.POST("/preview/", ui.handlePreview)
+	g.POST("/restore/:id/", ui.handleRestoreQuestion)
+	g.GET("/absences/", ui.handleAbsences)
+	g.POST("/absences/", ui.handleAbsencePost)
+	g.POST("/absences/delete/:id/", ui.handleAbsenceDelete)
+	g.GET("/templates/", ui.handleTemplates)
+	g.POST("/templates/from/:id/", ui.checkManager, ui.handleTemplateFromQuestion)
+	g.POST("/templates/delete/:id/", ui.checkManager, ui.handleTemplateDelete)
+	g.GET("/holidays/", ui.handleHolidays)
+	g.POST("/holidays/", ui.checkManager, ui.handleHolidayPost)
+	g.POST("/holidays/import/", ui.checkManager, ui.handleHolidayImport)
+	g.POST("/holidays/delete/:id/", ui.checkManager, ui.handleHolidayDelete)
 
 	err = r.Run(App.config.ListenAddress)
 	if err != nil {
@@ -72,40 +91,109 @@ func ServeUI() {
 	}
 }
 
-func (w *webUI) CreateToken(teamID, channel string) string {
+func (w *webUI) CreateToken(teamID, channel, user string) (string, error) {
 	token := WebToken{
 		Token:     uuid.NewString(),
 		CreatedAt: time.Now(),
 		Team:      teamID,
 		Channel:   channel,
+		User:      user,
+	}
+
+	err := App.store.DeleteSessionsBefore(time.Now().Add(-webTokenLifetime))
+	if err != nil {
+		return "", fmt.Errorf("could not delete expired sessions: %w", err)
 	}
 
-	w.tokens = append(w.tokens, token)
-	return token.Token
+	err = App.store.SaveSession(token)
+	if err != nil {
+		return "", err
+	}
+	return token.Token, nil
 }
 
 func (w *webUI) checkToken(ctx *gin.Context) {
-	token := ctx.Param("token")
-	team := ctx.Param("team")
-	channel := ctx.Param("channel")
-
-	var goodTokens []WebToken
-	ok := false
-	for _, webToken := range w.tokens {
-		if webToken.CreatedAt.Add(1 * time.Hour).Before(time.Now()) {
-			continue
-		}
-		goodTokens = append(goodTokens, webToken)
-		if webToken.Token == token && webToken.Channel == channel && webToken.Team == team {
-			ok = true
-		}
+	webToken, err := App.store.LoadSession(ctx.Param("token"))
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not load session: %w", err))
+		return
 	}
 
+	ok := webToken.Token != "" &&
+		webToken.CreatedAt.Add(webTokenLifetime).After(time.Now()) &&
+		webToken.Channel == ctx.Param("channel") &&
+		webToken.Team == ctx.Param("team")
+
 	if !ok {
 		ctx.String(http.StatusForbidden, "Invalid access token.")
 		ctx.Abort()
 		return
 	}
+	ctx.Set("session", webToken)
+	ctx.Next()
+}
+
+// checkManager only lets through users who may manage the whole team.
+func (w *webUI) checkManager(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+	if session.User == "" {
+		ctx.String(http.StatusForbidden, "Open a new link using /buzerator.")
+		ctx.Abort()
+		return
+	}
+
+	manager, err := sessionIsManager(ctx)
+	if err != nil {
+		w.error(ctx, err)
+		return
+	}
+	if !manager {
+		ctx.String(http.StatusForbidden, "Only managers can do this.")
+		ctx.Abort()
+		return
+	}
+	ctx.Next()
+}
+
+// sessionIsManager reports whether the user of the session may manage the whole team.
+// Sessions without a user are never managers.
+func sessionIsManager(ctx *gin.Context) (bool, error) {
+	session := ctx.MustGet("session").(WebToken)
+	if session.User == "" {
+		return false, nil
+	}
+
+	manager, err := IsManager(session.Team, session.User)
+	if err != nil {
+		return false, fmt.Errorf("could not check manager: %w", err)
+	}
+	return manager, nil
+}
+
+// loadChannelQuestion loads the question from the URL. Questions from other channels are not found,
+// in which case the response is already written and false is returned.
+func loadChannelQuestion(ctx *gin.Context) (Question, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.String(http.StatusNotFound, "Not found")
+		return Question{}, false
+	}
+
+	question, err := LoadQuestion(id)
+	if err != nil || question.Channel != ctx.Param("channel") {
+		ctx.String(http.StatusNotFound, "Not found")
+		return Question{}, false
+	}
+	return question, true
+}
+
+func (w *webUI) checkAdminToken(ctx *gin.Context) {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if App.config.AdminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(App.config.AdminToken)) != 1 {
+		ctx.String(http.StatusForbidden, "Invalid access token.")
+		ctx.Abort()
+		return
+	}
 	ctx.Next()
 }
 
@@ -123,6 +211,9 @@ func (w *webUI) newRenderer() multitemplate.Renderer {
 	r.Add("index", w.createTemplate("templates/index.gohtml"))
 	r.Add("question_list", w.createTemplate("templates/base.gohtml", "templates/question_list.gohtml"))
 	r.Add("question_form", w.createTemplate("templates/base.gohtml", "templates/question_form.gohtml"))
+	r.Add("absences", w.createTemplate("templates/base.gohtml", "templates/absences.gohtml"))
+	r.Add("holidays", w.createTemplate("templates/base.gohtml", "templates/holidays.gohtml"))
+	r.Add("templates", w.createTemplate("templates/base.gohtml", "templates/templates.gohtml"))
 	return r
 }
 
@@ -141,6 +232,7 @@ type userInfo struct {
 	ID       string
 	Name     string
 	Selected bool
+	Excluded bool
 }
 
 func (w *webUI) listChannelMembers(teamID string, channel string) ([]userInfo, error) {
@@ -165,31 +257,87 @@ func (w *webUI) listChannelMembers(teamID string, channel string) ([]userInfo, e
 	return userInfos, nil
 }
 
-func (w *webUI) handleQuestionList(ctx *gin.Context) {
-	var questions []Question
-
-	err := App.db.View(func(tx *bolt.Tx) error {
-		return tx.Bucket([]byte("questions")).ForEach(func(k, v []byte) error {
-			var q Question
-			err := json.Unmarshal(v, &q)
-			if err != nil {
-				return err
-			}
+// serverTimezone returns the name of the local time zone, which is used by questions without a time zone.
+func serverTimezone() string {
+	if tz := os.Getenv("TZ"); tz != "" {
+		return tz
+	}
+	return time.Local.String()
+}
 
-			if q.Channel != ctx.Param("channel") {
-				return nil
-			}
+type scheduledQuestion struct {
+	Question
+	NextRun time.Time // in the time zone of the question, zero if it cannot be computed
+}
+
+type trashedQuestion struct {
+	Question
+	PurgeAt time.Time // zero if the question is never purged
+}
+
+type userGroupInfo struct {
+	ID       string
+	Handle   string
+	Name     string
+	Selected bool
+}
 
-			questions = append(questions, q)
-			return nil
+// listUserGroups returns the user groups of the team. Listing them needs the usergroups:read scope,
+// so a failure only hides them from the form.
+func (w *webUI) listUserGroups(teamID string, selected []string) []userGroupInfo {
+	groups, err := ListUserGroups(teamID)
+	if err != nil {
+		log.Warn("Could not list user groups.", "team", teamID, "err", err)
+		return nil
+	}
+
+	var infos []userGroupInfo
+	for _, group := range groups {
+		infos = append(infos, userGroupInfo{
+			ID:       group.ID,
+			Handle:   group.Handle,
+			Name:     group.Name,
+			Selected: slices.Contains(selected, group.ID),
 		})
-	})
+	}
+	return infos
+}
+
+func (w *webUI) handleQuestionList(ctx *gin.Context) {
+	allQuestions, err := App.store.ListQuestions()
 	if err != nil {
 		w.error(ctx, fmt.Errorf("cannot load questions: %w", err))
 		return
 	}
 
-	w.render(ctx, "question_list", gin.H{"questions": questions})
+	now := time.Now()
+	var questions, upcoming, past []scheduledQuestion
+	var trash []trashedQuestion
+	for _, q := range allQuestions {
+		if q.Channel != ctx.Param("channel") {
+			continue
+		}
+
+		if q.IsDeleted() {
+			trash = append(trash, trashedQuestion{Question: q, PurgeAt: q.purgeAt()})
+			continue
+		}
+
+		nextRun, err := q.NextRun(now)
+		if err != nil {
+			log.Warn("Could not compute next run.", "question", q.ID, "err", err)
+		}
+		switch {
+		case q.IsOneOff() && len(q.RunAt) == 0:
+			past = append(past, scheduledQuestion{Question: q, NextRun: nextRun})
+		case q.IsOneOff():
+			upcoming = append(upcoming, scheduledQuestion{Question: q, NextRun: nextRun})
+		default:
+			questions = append(questions, scheduledQuestion{Question: q, NextRun: nextRun})
+		}
+	}
+
+	w.render(ctx, "question_list", gin.H{"questions": questions, "upcoming": upcoming, "past": past, "trash": trash})
 }
 
 func (w *webUI) handleNewQuestion(ctx *gin.Context) {
@@ -199,7 +347,42 @@ func (w *webUI) handleNewQuestion(ctx *gin.Context) {
 		return
 	}
 
-	w.render(ctx, "question_form", gin.H{"users": users})
+	templates, err := ListQuestionTemplates(ctx.Param("team"))
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not list templates: %w", err))
+		return
+	}
+
+	data := gin.H{
+		"users":          users,
+		"userGroups":     w.listUserGroups(ctx.Param("team"), nil),
+		"pollDays":       App.config.PollDays,
+		"archiveDays":    App.config.ArchiveDays,
+		"serverTimezone": serverTimezone(),
+		"prompts":        promptRows(nil),
+		"runAt":          runAtRows(nil),
+		"defaultLayout":  DefaultLayout,
+		"templates":      templates,
+	}
+
+	if ref := ctx.Query("template"); ref != "" {
+		template, ok, err := FindQuestionTemplate(ctx.Param("team"), ref)
+		if err != nil {
+			w.error(ctx, fmt.Errorf("could not load template: %w", err))
+			return
+		}
+		if !ok {
+			ctx.String(http.StatusNotFound, "Not found")
+			return
+		}
+
+		data["question"] = template.Question
+		data["userGroups"] = w.listUserGroups(ctx.Param("team"), template.Question.UserGroups)
+		data["prompts"] = promptRows(template.Question.Prompts)
+		data["template"] = ref
+	}
+
+	w.render(ctx, "question_form", data)
 }
 
 func (w *webUI) handleIndex(ctx *gin.Context) {
@@ -207,10 +390,241 @@ func (w *webUI) handleIndex(ctx *gin.Context) {
 }
 
 type questionForm struct {
-	Users   []string `binding:"required" form:"users"`
-	Message string   `binding:"required" form:"message"`
-	Cron    string   `binding:"required" form:"cron"`
-	Active  bool     `form:"active"`
+	Users        []string `form:"users"`
+	UserGroups   []string `form:"user_groups"`
+	Participants string   `form:"participants"`
+	Excluded     []string `form:"excluded"`
+	Rotation     string   `form:"rotation"`
+	RotationSize int      `binding:"min=0" form:"rotation_size"`
+	RotationNext string   `form:"rotation_next"`
+	RotationNew  bool     `form:"rotation_reset"`
+	Message      string   `binding:"required" form:"message"`
+	Layout       string   `form:"layout"`
+	Greetings    string   `form:"greetings"`   // one per line
+	Completions  string   `form:"completions"` // one per line
+	Schedule     string   `form:"schedule"`
+	Cron         string   `form:"cron"`
+	RunAt        []string `form:"run_at"`
+	Timezone     string   `form:"timezone"`
+	Holidays     string   `form:"holiday_policy"`
+	Deadline     string   `form:"deadline"`
+	Quorum       string   `form:"quorum"`
+	NotifyQuorum bool     `form:"notify_quorum"`
+	Announce     bool     `form:"announce_close"`
+	Prompts      []string `form:"prompts"`
+	Required     []string `form:"prompt_required"` // "1" or "0" for every prompt
+	Active       bool     `form:"active"`
+	SkipNext     bool     `form:"skip_next"`
+	PollDays     string   `form:"poll_days"`    // empty means the global default
+	ArchiveDays  string   `form:"archive_days"` // empty means the global default
+}
+
+// retentionDays parses the retention overrides, nil means the global default.
+func (f *questionForm) retentionDays() (pollDays *int, archiveDays *int, err error) {
+	pollDays, err = optionalDays(f.PollDays)
+	if err != nil {
+		return nil, nil, err
+	}
+	archiveDays, err = optionalDays(f.ArchiveDays)
+	return pollDays, archiveDays, err
+}
+
+func optionalDays(value string) (*int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return nil, fmt.Errorf("invalid number of days %q", value)
+	}
+	return &days, nil
+}
+
+// formLines splits a textarea into its non-empty lines.
+func formLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// runAt returns the filled in times of a one-off question, ordered and without duplicates.
+// Only times in the future are accepted.
+func (f *questionForm) runAt(loc *time.Location) ([]time.Time, error) {
+	var times []time.Time
+	for _, value := range f.RunAt {
+		if value == "" {
+			continue
+		}
+
+		t, err := time.ParseInLocation(runAtLayout, value, loc)
+		if err != nil {
+			return nil, err
+		}
+		if !t.After(time.Now()) {
+			return nil, fmt.Errorf("run time %s is in the past", value)
+		}
+		times = append(times, t)
+	}
+
+	slices.SortFunc(times, time.Time.Compare)
+	return slices.CompactFunc(times, time.Time.Equal), nil
+}
+
+// runAtLayout is the format of datetime-local inputs.
+const runAtLayout = "2006-01-02T15:04"
+
+// emptyRunAtRows is how many empty times are shown in the form for adding new ones.
+const emptyRunAtRows = 3
+
+func runAtRows(q *Question) []string {
+	var rows []string
+	if q != nil {
+		for _, t := range q.RunAt {
+			rows = append(rows, t.In(q.location()).Format(runAtLayout))
+		}
+	}
+	return append(rows, make([]string, emptyRunAtRows)...)
+}
+
+// emptyPromptRows is how many empty prompts are shown in the form for adding new ones.
+const emptyPromptRows = 3
+
+func promptRows(prompts []Prompt) []Prompt {
+	return append(slices.Clone(prompts), make([]Prompt, emptyPromptRows)...)
+}
+
+// prompts returns the filled in prompts, skipping empty rows.
+func (f *questionForm) prompts() []Prompt {
+	var prompts []Prompt
+	for i, label := range f.Prompts {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		prompts = append(prompts, Prompt{
+			Label:    label,
+			Required: i < len(f.Required) && f.Required[i] == "1",
+		})
+	}
+	return prompts
+}
+
+// apply validates the form and copies it to the question. The error describes the first invalid field.
+func (f *questionForm) apply(q *Question) error {
+	f.Timezone = strings.TrimSpace(f.Timezone)
+	if f.Timezone == "" {
+		// LoadLocation would take it as UTC, while questions without a time zone run in the server one
+		return fmt.Errorf("missing time zone")
+	}
+	loc, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid time zone")
+	}
+
+	f.Layout = strings.TrimSpace(f.Layout)
+	err = validateLayout(f.Layout)
+	if err != nil {
+		return fmt.Errorf("invalid layout: %w", err)
+	}
+
+	runAt, err := f.runAt(loc)
+	if err != nil {
+		return fmt.Errorf("invalid run time")
+	}
+
+	switch ScheduleKind(f.Schedule) {
+	case ScheduleCron:
+		if !gronx.New().IsValid(f.Cron) {
+			return fmt.Errorf("invalid cron expression")
+		}
+	case ScheduleOnce:
+		// questions which already ran can be edited without planning another run
+		if len(runAt) == 0 && len(q.PastRuns) == 0 {
+			return fmt.Errorf("no run times")
+		}
+	default:
+		return fmt.Errorf("invalid schedule")
+	}
+
+	f.Quorum = strings.TrimSpace(f.Quorum)
+	if f.Quorum != "" {
+		_, _, err = parseQuorum(f.Quorum)
+		if err != nil {
+			return fmt.Errorf("invalid quorum")
+		}
+	}
+
+	f.Deadline = strings.TrimSpace(f.Deadline)
+	_, err = (&Question{Deadline: f.Deadline}).deadlineAt(time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid deadline")
+	}
+
+	pollDays, archiveDays, err := f.retentionDays()
+	if err != nil {
+		return fmt.Errorf("invalid retention")
+	}
+
+	switch RotationMode(f.Rotation) {
+	case RotationNone, RotationRoundRobin, RotationRandom, RotationFacilitator:
+	default:
+		return fmt.Errorf("invalid rotation")
+	}
+
+	switch HolidayPolicy(f.Holidays) {
+	case HolidaysIgnore, HolidaysSkip, HolidaysShift:
+	default:
+		return fmt.Errorf("invalid holiday policy")
+	}
+
+	switch ParticipantMode(f.Participants) {
+	case ParticipantsUsers:
+		if len(f.Users) == 0 && len(f.UserGroups) == 0 {
+			return fmt.Errorf("no users selected")
+		}
+	case ParticipantsChannel:
+	default:
+		return fmt.Errorf("invalid participants")
+	}
+
+	q.Message = f.Message
+	q.Layout = f.Layout
+	q.Greetings = formLines(f.Greetings)
+	q.Completions = formLines(f.Completions)
+	q.Prompts = f.prompts()
+	q.Users = f.Users
+	q.UserGroups = f.UserGroups
+	q.Participants = ParticipantMode(f.Participants)
+	q.ExcludedUsers = f.Excluded
+	if f.RotationNew || q.Rotation != RotationMode(f.Rotation) {
+		q.RotationQueue = nil
+	}
+	q.Rotation = RotationMode(f.Rotation)
+	q.RotationSize = f.RotationSize
+	if f.RotationNext != "" {
+		q.moveToFront(f.RotationNext)
+	}
+	q.Schedule = ScheduleKind(f.Schedule)
+	q.Cron = f.Cron
+	q.RunAt = runAt
+	q.Timezone = f.Timezone
+	q.HolidayPolicy = HolidayPolicy(f.Holidays)
+	q.Quorum = f.Quorum
+	q.NotifyQuorum = f.NotifyQuorum
+	q.Deadline = f.Deadline
+	q.AnnounceClose = f.Announce
+	q.IsActive = f.Active
+	q.SkipNextRun = f.SkipNext
+	q.PollDays = pollDays
+	q.ArchiveDays = archiveDays
+	return nil
 }
 
 func (w *webUI) handleNewQuestionPost(ctx *gin.Context) {
@@ -221,22 +635,17 @@ func (w *webUI) handleNewQuestionPost(ctx *gin.Context) {
 		return
 	}
 
-	gron := gronx.New()
-	valid := gron.IsValid(data.Cron)
-	if !valid {
-		ctx.String(http.StatusBadRequest, "Invalid cron expression.")
+	question := Question{
+		TeamID:  ctx.Param("team"),
+		Channel: ctx.Param("channel"),
+		Owner:   ctx.MustGet("session").(WebToken).User,
+	}
+	err = data.apply(&question)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "Invalid form data: %s.", err)
 		return
 	}
 
-	question := Question{
-		TeamID:          ctx.Param("team"),
-		Channel:         ctx.Param("channel"),
-		Message:         data.Message,
-		Users:           data.Users,
-		Cron:            data.Cron,
-		CurrentInstance: "",
-		IsActive:        data.Active,
-	}
 	err = question.Save()
 	if err != nil {
 		ctx.String(http.StatusInternalServerError, "Server Error")
@@ -247,15 +656,8 @@ func (w *webUI) handleNewQuestionPost(ctx *gin.Context) {
 }
 
 func (w *webUI) handleEditQuestion(ctx *gin.Context) {
-	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
-	if err != nil {
-		ctx.String(http.StatusNotFound, "Not found")
-		return
-	}
-
-	question, err := LoadQuestion(id)
-	if err != nil || question.Channel != ctx.Param("channel") {
-		ctx.String(http.StatusNotFound, "Not found")
+	question, ok := loadChannelQuestion(ctx)
+	if !ok {
 		return
 	}
 
@@ -274,42 +676,81 @@ func (w *webUI) handleEditQuestion(ctx *gin.Context) {
 			}
 		}
 		users[i].Selected = selected
+		users[i].Excluded = slices.Contains(question.ExcludedUsers, user.ID)
 	}
 
-	w.render(ctx, "question_form", gin.H{"users": users, "question": question})
-}
+	manager, err := sessionIsManager(ctx)
+	if err != nil {
+		w.error(ctx, err)
+		return
+	}
 
-func (w *webUI) handleEditQuestionPost(ctx *gin.Context) {
-	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	stats, err := QuestionRoundStats(question.ID)
 	if err != nil {
-		ctx.String(http.StatusNotFound, "Not found")
+		w.error(ctx, fmt.Errorf("could not compute round stats: %w", err))
 		return
 	}
 
-	question, err := LoadQuestion(id)
-	if err != nil || question.Channel != ctx.Param("channel") {
-		ctx.String(http.StatusNotFound, "Not found")
+	var rotationQueue []userInfo
+	for _, id := range question.RotationQueue {
+		i := slices.IndexFunc(users, func(user userInfo) bool { return user.ID == id })
+		if i != -1 {
+			rotationQueue = append(rotationQueue, users[i])
+			continue
+		}
+
+		name, err := LoadMemberName(question.TeamID, id)
+		if err != nil {
+			name = id
+		}
+		rotationQueue = append(rotationQueue, userInfo{ID: id, Name: name})
+	}
+
+	var instance QuestionInstance
+	if question.CurrentInstance != "" {
+		instance, err = App.store.LoadInstance(question.Channel, question.CurrentInstance)
+		if err != nil {
+			w.error(ctx, fmt.Errorf("could not load current instance: %w", err))
+			return
+		}
+	}
+
+	w.render(ctx, "question_form", gin.H{
+		"users":          users,
+		"userGroups":     w.listUserGroups(question.TeamID, question.UserGroups),
+		"question":       question,
+		"pollDays":       App.config.PollDays,
+		"archiveDays":    App.config.ArchiveDays,
+		"canClose":       instance.QuestionID != 0 && instance.IsOpen(),
+		"serverTimezone": serverTimezone(),
+		"prompts":        promptRows(question.Prompts),
+		"runAt":          runAtRows(&question),
+		"defaultLayout":  DefaultLayout,
+		"rotationQueue":  rotationQueue,
+		"manager":        manager,
+		"stats":          stats,
+	})
+}
+
+func (w *webUI) handleEditQuestionPost(ctx *gin.Context) {
+	question, ok := loadChannelQuestion(ctx)
+	if !ok {
 		return
 	}
 
 	var data questionForm
-	err = ctx.Bind(&data)
+	err := ctx.Bind(&data)
 	if err != nil {
 		ctx.String(400, "Invalid form data.")
 		return
 	}
 
-	gron := gronx.New()
-	valid := gron.IsValid(data.Cron)
-	if !valid {
-		ctx.String(http.StatusBadRequest, "Invalid cron expression.")
+	err = data.apply(&question)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "Invalid form data: %s.", err)
 		return
 	}
 
-	question.Message = data.Message
-	question.Users = data.Users
-	question.Cron = data.Cron
-	question.IsActive = data.Active
 	err = question.Save()
 	if err != nil {
 		w.error(ctx, fmt.Errorf("could not save question: %w", err))
@@ -319,26 +760,459 @@ func (w *webUI) handleEditQuestionPost(ctx *gin.Context) {
 	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
 }
 
+var (
+	previewMention = regexp.MustCompile(`<@([^>|]+)>`)
+	previewDate    = regexp.MustCompile(`<!date\^[^|>]*\|([^>]*)>`)
+)
+
+// handlePreview renders the message of the question being edited with sample participants,
+// both for an open and a closed round.
+func (w *webUI) handlePreview(ctx *gin.Context) {
+	var data questionForm
+	// the form may be incomplete while it is being edited, the preview is rendered anyway
+	_ = ctx.ShouldBind(&data)
+
+	question := Question{
+		Message:     data.Message,
+		Prompts:     data.prompts(),
+		Layout:      strings.TrimSpace(data.Layout),
+		Greetings:   formLines(data.Greetings),
+		Completions: formLines(data.Completions),
+		Timezone:    data.Timezone,
+		Deadline:    strings.TrimSpace(data.Deadline),
+		Quorum:      strings.TrimSpace(data.Quorum),
+	}
+
+	qi := QuestionInstance{
+		Question:   &question,
+		Round:      1,
+		Greeting:   question.pickGreeting(),
+		Completion: question.pickCompletion(),
+		Responses: map[string]ResponseStatus{
+			"Jana":  ResponseAnswered,
+			"Peter": ResponseMissing,
+			"Zuzka": ResponseExcused,
+		},
+		Status: StatusOpen,
+	}
+	if RotationMode(data.Rotation) == RotationFacilitator {
+		qi.Facilitator = "Jana"
+	}
+	qi.Deadline, _ = question.deadlineAt(time.Now())
+
+	previews := gin.H{}
+	for _, status := range []InstanceStatus{StatusOpen, StatusClosed} {
+		if status == StatusClosed {
+			qi.Responses["Marek"] = ResponseLate
+			tally := qi.tally()
+			qi.Status = status
+			qi.Tally = &tally
+		}
+
+		message, err := executeLayout(question.Layout, qi.messageData())
+		if err != nil {
+			ctx.JSON(http.StatusOK, gin.H{"error": err.Error()})
+			return
+		}
+
+		message = previewMention.ReplaceAllString(message, "@$1")
+		previews[string(status)] = previewDate.ReplaceAllString(message, "$1")
+	}
+
+	ctx.JSON(http.StatusOK, previews)
+}
+
 func (w *webUI) handleInvokeQuestion(ctx *gin.Context) {
+	question, ok := loadChannelQuestion(ctx)
+	if !ok {
+		return
+	}
+	if question.IsDeleted() {
+		// a question in the trash must not post new rounds until it is restored
+		ctx.String(http.StatusBadRequest, "Question is in the trash")
+		return
+	}
+
+	err := question.NewInstance()
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not invoke question: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleCloseQuestion(ctx *gin.Context) {
+	question, ok := loadChannelQuestion(ctx)
+	if !ok {
+		return
+	}
+
+	err := question.CloseCurrentInstance()
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not close instance: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleDeleteQuestion(ctx *gin.Context) {
+	question, ok := loadChannelQuestion(ctx)
+	if !ok {
+		return
+	}
+
+	err := question.Delete(DeletedByUser)
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not delete question: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleRestoreQuestion(ctx *gin.Context) {
+	question, ok := loadChannelQuestion(ctx)
+	if !ok {
+		return
+	}
+
+	err := question.Restore()
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not restore question: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+type absenceInfo struct {
+	Absence
+	Name      string
+	CanDelete bool
+}
+
+func (w *webUI) handleAbsences(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+	if session.User == "" {
+		ctx.String(http.StatusForbidden, "Open a new link using /buzerator.")
+		return
+	}
+
+	manager, err := sessionIsManager(ctx)
+	if err != nil {
+		w.error(ctx, err)
+		return
+	}
+
+	users, err := w.listChannelMembers(session.Team, session.Channel)
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not get channel members: %w", err))
+		return
+	}
+	names := map[string]string{}
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+	if !manager {
+		users = slices.DeleteFunc(users, func(user userInfo) bool {
+			return user.ID != session.User
+		})
+	}
+
+	allAbsences, err := App.store.ListAbsences(session.Team)
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not list absences: %w", err))
+		return
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	var absences []absenceInfo
+	for _, absence := range allAbsences {
+		// only current and future absences of people in this channel are shown
+		if _, ok := names[absence.User]; !ok || absence.To < today {
+			continue
+		}
+
+		absences = append(absences, absenceInfo{
+			Absence:   absence,
+			Name:      names[absence.User],
+			CanDelete: manager || absence.User == session.User,
+		})
+	}
+
+	w.render(ctx, "absences", gin.H{"absences": absences, "users": users, "currentUser": session.User, "today": today})
+}
+
+type absenceForm struct {
+	User string `binding:"required" form:"user"`
+	From string `binding:"required" form:"from"`
+	To   string `form:"to"`
+}
+
+func (w *webUI) handleAbsencePost(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+	if session.User == "" {
+		ctx.String(http.StatusForbidden, "Open a new link using /buzerator.")
+		return
+	}
+
+	var data absenceForm
+	err := ctx.Bind(&data)
+	if err != nil {
+		ctx.String(400, "Invalid form data.")
+		return
+	}
+
+	if data.User != session.User {
+		manager, err := sessionIsManager(ctx)
+		if err != nil {
+			w.error(ctx, err)
+			return
+		}
+		if !manager {
+			ctx.String(http.StatusForbidden, "Only managers can set absences of others.")
+			return
+		}
+	}
+
+	absence, err := NewAbsence(session.Team, data.User, data.From, data.To, session.User)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "Invalid dates.")
+		return
+	}
+
+	err = absence.Save()
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not save absence: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/absences/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleAbsenceDelete(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil || session.User == "" {
+		ctx.String(http.StatusNotFound, "Not found")
+		return
+	}
+
+	absences, err := App.store.ListAbsences(session.Team)
 	if err != nil {
+		w.error(ctx, fmt.Errorf("could not list absences: %w", err))
+		return
+	}
+	index := slices.IndexFunc(absences, func(a Absence) bool {
+		return a.ID == id
+	})
+	if index == -1 {
 		ctx.String(http.StatusNotFound, "Not found")
 		return
 	}
+	absence := absences[index]
 
-	question, err := LoadQuestion(id)
-	if err != nil || question.Channel != ctx.Param("channel") {
+	if absence.User != session.User {
+		manager, err := sessionIsManager(ctx)
+		if err != nil {
+			w.error(ctx, err)
+			return
+		}
+		if !manager {
+			ctx.String(http.StatusForbidden, "Only managers can delete absences of others.")
+			return
+		}
+	}
+
+	err = absence.Delete()
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not delete absence: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/absences/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleTemplates(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+
+	manager, err := sessionIsManager(ctx)
+	if err != nil {
+		w.error(ctx, err)
+		return
+	}
+
+	templates, err := App.store.ListTemplates(session.Team)
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not list templates: %w", err))
+		return
+	}
+
+	w.render(ctx, "templates", gin.H{"builtin": BuiltinTemplates, "templates": templates, "manager": manager})
+}
+
+type templateForm struct {
+	Name string `binding:"required" form:"name"`
+}
+
+func (w *webUI) handleTemplateFromQuestion(ctx *gin.Context) {
+	question, ok := loadChannelQuestion(ctx)
+	if !ok {
+		return
+	}
+
+	var data templateForm
+	err := ctx.Bind(&data)
+	if err != nil || strings.TrimSpace(data.Name) == "" {
+		ctx.String(400, "Invalid form data.")
+		return
+	}
+
+	template := NewQuestionTemplate(strings.TrimSpace(data.Name), question)
+	err = template.Save()
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not save template: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/templates/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleTemplateDelete(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
 		ctx.String(http.StatusNotFound, "Not found")
 		return
 	}
 
-	err = question.NewInstance()
+	err = App.store.DeleteTemplate(session.Team, id)
 	if err != nil {
-		w.error(ctx, fmt.Errorf("could not invoke question: %w", err))
+		w.error(ctx, fmt.Errorf("could not delete template: %w", err))
 		return
 	}
 
-	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/templates/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleHolidays(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+
+	manager, err := sessionIsManager(ctx)
+	if err != nil {
+		w.error(ctx, err)
+		return
+	}
+
+	allHolidays, err := App.store.ListHolidays(session.Team)
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not list holidays: %w", err))
+		return
+	}
+
+	// holidays of past years are not interesting anymore
+	year := time.Now().Format("2006")
+	var holidays []Holiday
+	for _, holiday := range allHolidays {
+		if holiday.Date >= year {
+			holidays = append(holidays, holiday)
+		}
+	}
+
+	w.render(ctx, "holidays", gin.H{"holidays": holidays, "manager": manager, "imported": ctx.Query("imported")})
+}
+
+type holidayForm struct {
+	Date string `binding:"required" form:"date"`
+	Name string `form:"name"`
+}
+
+func (w *webUI) handleHolidayPost(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+
+	var data holidayForm
+	err := ctx.Bind(&data)
+	if err != nil {
+		ctx.String(400, "Invalid form data.")
+		return
+	}
+
+	_, err = time.Parse(time.DateOnly, data.Date)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "Invalid date.")
+		return
+	}
+
+	_, err = AddHolidays(session.Team, []Holiday{{Date: data.Date, Name: strings.TrimSpace(data.Name)}})
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not save holiday: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/holidays/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleHolidayImport(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+
+	file, err := ctx.FormFile("calendar")
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "Missing calendar file.")
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not open uploaded file: %w", err))
+		return
+	}
+	defer f.Close()
+
+	holidays, err := ParseICS(f)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "Invalid calendar file: %s", err)
+		return
+	}
+
+	count, err := AddHolidays(session.Team, holidays)
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not save holidays: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/holidays/?imported=%d", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token"), count))
+}
+
+func (w *webUI) handleHolidayDelete(ctx *gin.Context) {
+	session := ctx.MustGet("session").(WebToken)
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.String(http.StatusNotFound, "Not found")
+		return
+	}
+
+	err = App.store.DeleteHoliday(session.Team, id)
+	if err != nil {
+		w.error(ctx, fmt.Errorf("could not delete holiday: %w", err))
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, fmt.Sprintf("/%s/%s/%s/holidays/", ctx.Param("team"), ctx.Param("channel"), ctx.Param("token")))
+}
+
+func (w *webUI) handleBackup(ctx *gin.Context) {
+	filename := backupPrefix + time.Now().Format(backupTimeFormat) + backupExtension()
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	_, err := App.store.Backup(ctx.Writer)
+	if err != nil {
+		// the response has likely been partially sent already, so only log the error
+		log.Error("Could not stream backup.", "err", err)
+		ctx.Abort()
+	}
 }
 
 func (w *webUI) handleCallback(ctx *gin.Context) {
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuestionFormApply(t *testing.T) {
+	valid := func() questionForm {
+		return questionForm{
+			Message:      "Čo nové?",
+			Participants: string(ParticipantsChannel),
+			Schedule:     string(ScheduleCron),
+			Cron:         "0 9 * * 1-5",
+			Timezone:     "Europe/Bratislava",
+		}
+	}
+
+	tests := []struct {
+		name     string
+		change   func(f *questionForm)
+		question Question
+		wantErr  bool
+	}{
+		{"valid", func(f *questionForm) {}, Question{}, false},
+		{"empty time zone", func(f *questionForm) { f.Timezone = " " }, Question{}, true},
+		{"unknown time zone", func(f *questionForm) { f.Timezone = "Mars/Olympus" }, Question{}, true},
+		{"invalid cron", func(f *questionForm) { f.Cron = "every day" }, Question{}, true},
+		{"one-off without runs", func(f *questionForm) { f.Schedule = string(ScheduleOnce) }, Question{}, true},
+		{"one-off which already ran", func(f *questionForm) { f.Schedule = string(ScheduleOnce) },
+			Question{PastRuns: []time.Time{time.Now().Add(-time.Hour)}}, false},
+		{"invalid quorum", func(f *questionForm) { f.Quorum = "150%" }, Question{}, true},
+		{"invalid deadline", func(f *questionForm) { f.Deadline = "soon" }, Question{}, true},
+		{"invalid retention", func(f *questionForm) { f.PollDays = "-1" }, Question{}, true},
+		{"invalid rotation", func(f *questionForm) { f.Rotation = "sometimes" }, Question{}, true},
+		{"invalid holiday policy", func(f *questionForm) { f.Holidays = "party" }, Question{}, true},
+		{"users without any selected", func(f *questionForm) { f.Participants = string(ParticipantsUsers) }, Question{}, true},
+		{"invalid layout", func(f *questionForm) { f.Layout = "{{.Nope" }, Question{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := valid()
+			tt.change(&form)
+			question := tt.question
+			err := form.apply(&question)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (question.Message != form.Message || question.Timezone != form.Timezone) {
+				t.Errorf("apply() did not copy the form: %+v", question)
+			}
+		})
+	}
+
+	t.Run("rotation reset", func(t *testing.T) {
+		form := valid()
+		form.Rotation = string(RotationRandom)
+		question := Question{Rotation: RotationRoundRobin, RotationQueue: []string{"U1", "U2"}}
+		err := form.apply(&question)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if question.RotationQueue != nil {
+			t.Errorf("changing the rotation kept the queue %v", question.RotationQueue)
+		}
+	})
+}